@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	commandLine "github.com/vpominchuk/wmetrics/src/args"
+	"github.com/vpominchuk/wmetrics/src/compare"
+	"github.com/vpominchuk/wmetrics/src/formatter"
+	"log"
+	"os"
+	"strings"
+)
+
+// runCompare compares the json outputs of two tests and exits with exitCodeRegression if any metric regressed
+func runCompare(commandLineArguments []string) {
+	arguments, files := commandLine.GetCompareArguments(commandLineArguments)
+
+	if err := commandLine.ValidateCompare(arguments); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(files) != 2 {
+		fmt.Printf("Error: expected baseline and current json files, got %d files\n", len(files))
+		os.Exit(1)
+	}
+
+	baseline, err := compare.Load(files[0])
+
+	if err != nil {
+		log.Fatalf("Error: %s: %v\n", files[0], err)
+	}
+
+	current, err := compare.Load(files[1])
+
+	if err != nil {
+		log.Fatalf("Error: %s: %v\n", files[1], err)
+	}
+
+	comparison := compare.Compare(
+		baseline, current, compare.Tolerances{
+			Latency:           *arguments.LatencyTolerance.Value,
+			RequestsPerSecond: *arguments.RpsTolerance.Value,
+			ErrorRate:         *arguments.ErrorRateTolerance.Value,
+		},
+	)
+
+	switch strings.ToLower(*arguments.OutputFormat.Value) {
+	case "std", "text":
+		formatter.PrintComparison(os.Stdout, comparison)
+	case "json":
+		formatter.PrintJson(os.Stdout, comparison, false)
+	case "json-pretty":
+		formatter.PrintJson(os.Stdout, comparison, true)
+	}
+
+	if comparison.HasRegression() {
+		os.Exit(exitCodeRegression)
+	}
+}
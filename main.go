@@ -2,24 +2,115 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"github.com/schollz/progressbar/v3"
 	"github.com/vpominchuk/wmetrics/src/app"
 	commandLine "github.com/vpominchuk/wmetrics/src/args"
+	"github.com/vpominchuk/wmetrics/src/dump"
+	"github.com/vpominchuk/wmetrics/src/exporters"
 	"github.com/vpominchuk/wmetrics/src/formatter"
+	"github.com/vpominchuk/wmetrics/src/otlp"
+	"github.com/vpominchuk/wmetrics/src/prometheus"
+	"github.com/vpominchuk/wmetrics/src/rawlog"
 	"github.com/vpominchuk/wmetrics/src/statistics"
 	"github.com/vpominchuk/wmetrics/src/tester"
+	"github.com/vpominchuk/wmetrics/src/thresholds"
+	"io"
 	"log"
 	"net/url"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 )
 
+const (
+	exitCodeErrors           = 1
+	exitCodeAssertionsFailed = 3
+	exitCodeThresholdsFailed = 4
+	exitCodeRegression       = 5
+	exitCodeInterrupted      = 130
+)
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		runReport(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		runCompare(os.Args[2:])
+		return
+	}
+
 	parameters := getCLIParameters()
 
 	correctNumberOfRequests(&parameters)
+	applyStages(&parameters)
+
+	collector := statistics.NewCollector(
+		statistics.Options{
+			Stages:           parameters.Stages,
+			Percentiles:      parameters.Percentiles,
+			TimelineInterval: parameters.TimelineInterval,
+			Histograms:       exportsHistograms(parameters.OutputFormat, parameters.OutputHistograms),
+			Assertions:       assertionNames(parameters.Assertions),
+			SlowestRequests:  parameters.SlowestRequests,
+			FastestRequests:  parameters.FastestRequests,
+		},
+	)
+
+	sinks := []tester.ResultSink{collector}
+
+	var rawLog *rawlog.Writer
+
+	if parameters.RawLogFile != "" {
+		var err error
+		rawLog, err = rawlog.New(
+			parameters.RawLogFile, rawlog.Run{
+				Stages:     parameters.Stages,
+				Assertions: assertionNames(parameters.Assertions),
+				Thresholds: parameters.Thresholds,
+			},
+		)
+
+		if err != nil {
+			log.Fatalf("Error: %v\n", err)
+		}
+
+		sinks = append(sinks, rawLog)
+	}
+
+	var exporter *prometheus.Exporter
+
+	if parameters.PrometheusAddress != "" {
+		exporter = prometheus.New()
+
+		if err := exporter.Start(parameters.PrometheusAddress); err != nil {
+			log.Fatalf("Error: failed to serve prometheus metrics: %v\n", err)
+		}
+
+		sinks = append(sinks, exporter)
+	}
+
+	pusher, err := newPusher(parameters)
+
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+
+	if pusher != nil {
+		sinks = append(sinks, pusher)
+	}
+
+	var spanExporter *otlp.Exporter
+
+	if parameters.OtlpUrl != "" {
+		spanExporter = otlp.New(parameters.OtlpUrl, parameters.Method)
+		sinks = append(sinks, spanExporter)
+	}
 
 	if canPrintGreetings(parameters.OutputFormat) {
 		showGreetings(parameters)
@@ -31,7 +122,31 @@ func main() {
 		bar = buildProgressBar(parameters)
 	}
 
-	results, testDuration, err := tester.Test(
+	dumper, err := newDumper(parameters, bar)
+
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+
+	if dumper != nil {
+		sinks = append(sinks, dumper)
+	}
+
+	if pusher != nil {
+		pusher.Start()
+	}
+
+	if spanExporter != nil {
+		spanExporter.Start()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handleInterruptSignals(cancel, parameters)
+
+	testDuration, err := tester.Test(
+		ctx,
 		parameters,
 		func(progress tester.RequestsProgress) {
 			if bar != nil {
@@ -42,35 +157,112 @@ func main() {
 				}
 			}
 		},
+		sinks...,
 	)
 
 	if err != nil {
 		log.Fatalf("Error: %v\n", err)
 	}
 
-	if len(results) == 0 {
+	if rawLog != nil {
+		if err := rawLog.Close(); err != nil {
+			stdError(fmt.Sprintf("* Warning: Failed to write the raw result log: %v\n", err))
+		}
+	}
+
+	if dumper != nil {
+		if err := dumper.Close(); err != nil {
+			stdError(fmt.Sprintf("* Warning: Failed to write the request dumps: %v\n", err))
+		}
+	}
+
+	if exporter != nil {
+		_ = exporter.Close()
+	}
+
+	if pusher != nil {
+		if err := pusher.Close(); err != nil {
+			stdError(fmt.Sprintf("* Warning: Failed to push the metrics: %v\n", err))
+		}
+	}
+
+	if spanExporter != nil {
+		if err := spanExporter.Close(); err != nil {
+			stdError(fmt.Sprintf("* Warning: Failed to export the spans: %v\n", err))
+		}
+	}
+
+	// the test has been interrupted during the warm-up or before any request of the measured run completed
+	if ctx.Err() != nil && (testDuration == 0 || collector.TotalRequests() == 0) {
+		stdError("\n* Interrupted before any request was measured, no results\n")
+		os.Exit(exitCodeInterrupted)
+	}
+
+	if collector.TotalRequests() == 0 {
 		log.Fatalf("Error: something went wrong. No test results\n")
 	}
 
-	stat, _ := statistics.GetStatistics(results, testDuration)
+	stat, _ := collector.GetStatistics(testDuration)
+
+	if ctx.Err() != nil {
+		stat.MarkInterrupted()
+	}
+
+	thresholds.Evaluate(parseThresholds(parameters.Thresholds), stat)
 
 	if canPrintGreetings(parameters.OutputFormat) {
 		fmt.Print("\n\n\n")
 	}
 
-	printResults(parameters.OutputFormat, stat)
+	printResults(parameters, stat)
 
 	if canPrintGreetings(parameters.OutputFormat) {
 		fmt.Printf("\n")
 	}
 
+	if thresholds.Failed(stat) {
+		os.Exit(exitCodeThresholdsFailed)
+	}
+
+	if haveFailedAssertions(stat) {
+		os.Exit(exitCodeAssertionsFailed)
+	}
+
+	// the partial results are printed, but a shortened run must not look like a complete one
+	if ctx.Err() != nil {
+		os.Exit(exitCodeInterrupted)
+	}
+
 	if haveErrors(stat) {
-		os.Exit(1)
+		os.Exit(exitCodeErrors)
 	}
 
 	os.Exit(0)
 }
 
+// handleInterruptSignals stops the test on the first signal, so the partial results can be printed.
+// The second signal terminates the process immediately.
+func handleInterruptSignals(cancel context.CancelFunc, parameters tester.Parameters) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+
+		stdError(
+			fmt.Sprintf(
+				"\n* Interrupted, waiting up to %s for requests in flight. Interrupt again to abort immediately\n",
+				parameters.GracePeriod,
+			),
+		)
+
+		cancel()
+
+		<-signals
+		os.Exit(exitCodeInterrupted)
+	}()
+}
+
 func haveErrors(stat statistics.Statistics) bool {
 	for _, singleUrlStat := range stat {
 		if len(singleUrlStat.Errors) > 0 {
@@ -81,6 +273,84 @@ func haveErrors(stat statistics.Statistics) bool {
 	return false
 }
 
+func haveFailedAssertions(stat statistics.Statistics) bool {
+	for _, singleUrlStat := range stat {
+		for _, assertion := range singleUrlStat.Assertions {
+			if assertion.Failed > 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func parseThresholds(expressions []string) []thresholds.Threshold {
+	result := make([]thresholds.Threshold, 0, len(expressions))
+
+	for _, expression := range expressions {
+		threshold, err := thresholds.Parse(expression)
+
+		if err != nil {
+			log.Fatalf("Error: %v\n", err)
+		}
+
+		result = append(result, threshold)
+	}
+
+	return result
+}
+
+func assertionNames(assertions []tester.Assertion) []string {
+	names := make([]string, 0, len(assertions))
+
+	for _, assertion := range assertions {
+		names = append(names, assertion.Name)
+	}
+
+	return names
+}
+
+// newPusher creates the pusher of the metrics to the time-series backends, nil if none is configured
+func newPusher(parameters tester.Parameters) (*exporters.Pusher, error) {
+	backends := make([]exporters.Exporter, 0)
+
+	if parameters.InfluxUrl != "" {
+		backends = append(backends, exporters.NewInflux(parameters.InfluxUrl, parameters.ExportInterval))
+	}
+
+	if parameters.StatsdAddress != "" {
+		statsd, err := exporters.NewStatsd(parameters.StatsdAddress)
+
+		if err != nil {
+			return nil, err
+		}
+
+		backends = append(backends, statsd)
+	}
+
+	if parameters.GraphiteAddress != "" {
+		backends = append(backends, exporters.NewGraphite(parameters.GraphiteAddress, parameters.ExportInterval))
+	}
+
+	if len(backends) == 0 {
+		return nil, nil
+	}
+
+	runId := parameters.RunId
+
+	if runId == "" {
+		runId = time.Now().Format("20060102-150405")
+	}
+
+	tags := []exporters.Tag{
+		{Name: "method", Value: parameters.Method},
+		{Name: "run_id", Value: runId},
+	}
+
+	return exporters.NewPusher(backends, parameters.ExportInterval, parameters.Percentiles, tags), nil
+}
+
 func canPrintProgressBar(format string) bool {
 	return strings.ToLower(format) == "std"
 }
@@ -89,15 +359,95 @@ func canPrintGreetings(format string) bool {
 	return strings.ToLower(format) == "std" || strings.ToLower(format) == "text"
 }
 
-func printResults(format string, stat statistics.Statistics) {
-	switch strings.ToLower(format) {
+// exportsHistograms reports if the statistics need the histogram buckets, the html report draws its charts from them
+func exportsHistograms(format string, outputHistograms bool) bool {
+	return outputHistograms || strings.ToLower(format) == "html"
+}
+
+func printResults(parameters tester.Parameters, stat statistics.Statistics) {
+	output, header, err := openOutput(parameters)
+
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+
+	switch strings.ToLower(parameters.OutputFormat) {
 	case "std", "text":
-		formatter.PrintResults(stat)
+		formatter.PrintResults(output, stat, parameters.SortBy)
 	case "json":
-		formatter.PrintJsonResults(stat, false)
+		formatter.PrintJsonResults(output, stat, false)
 	case "json-pretty":
-		formatter.PrintJsonResults(stat, true)
+		formatter.PrintJsonResults(output, stat, true)
+	case "csv":
+		err = printCsvResults(parameters, output, stat, ',', header)
+	case "tsv":
+		err = printCsvResults(parameters, output, stat, '\t', header)
+	case "html":
+		err = formatter.PrintHtmlResults(output, parameters, stat, parameters.SortBy)
+	}
+
+	if output != os.Stdout {
+		if closeErr := output.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		log.Fatalf("Error: failed to write the results: %v\n", err)
+	}
+}
+
+// printCsvResults appends the rows to the output file only if it has the same columns
+func printCsvResults(
+	parameters tester.Parameters, output io.Writer, stat statistics.Statistics, separator rune, header bool,
+) error {
+	if header {
+		return formatter.PrintCsvResults(output, stat, separator, nil)
+	}
+
+	file, err := os.Open(parameters.OutputFile)
+
+	if err != nil {
+		return err
 	}
+
+	existingHeader, err := formatter.ReadCsvHeader(file, separator)
+	_ = file.Close()
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", parameters.OutputFile, err)
+	}
+
+	return formatter.PrintCsvResults(output, stat, separator, existingHeader)
+}
+
+// openOutput opens the output file (-o) or returns the standard output. The csv and tsv
+// header is only needed if the results are not appended to a file that already has rows.
+func openOutput(parameters tester.Parameters) (*os.File, bool, error) {
+	if parameters.OutputFile == "" {
+		return os.Stdout, true, nil
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+
+	if parameters.OutputAppend {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(parameters.OutputFile, flags, 0644)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		_ = file.Close()
+		return nil, false, err
+	}
+
+	return file, info.Size() == 0, nil
 }
 
 func getCLIParameters() tester.Parameters {
@@ -122,6 +472,12 @@ func getCLIParameters() tester.Parameters {
 		os.Exit(1)
 	}
 
+	rate, _ := commandLine.ParseRate(*arguments.Rate.Value)
+	stages, _ := commandLine.GetStages(arguments)
+	warmupRequests, warmupDuration, _ := commandLine.ParseWarmup(*arguments.Warmup.Value)
+	percentiles, _ := commandLine.ParsePercentiles(*arguments.Percentiles.Value)
+	assertions, _ := commandLine.ParseAssertions(*arguments.Assertions.Value)
+
 	resources := make([]tester.Resource, 0, len(urls))
 
 	for _, link := range urls {
@@ -143,11 +499,14 @@ func getCLIParameters() tester.Parameters {
 		Resources:             resources,
 		Requests:              *arguments.Requests.Value,
 		Concurrency:           *arguments.Concurrency.Value,
+		Rate:                  rate,
+		Stages:                stages,
 		Timeout:               *arguments.Timeout.Value,
 		Method:                strings.ToUpper(*arguments.Method.Value),
 		UserAgent:             *arguments.UserAgent.Value,
 		UserAgentTemplate:     *arguments.UserAgentTemplate.Value,
 		KeepAlive:             *arguments.KeepAlive.Value,
+		ConnectionPool:        *arguments.ConnectionPool.Value,
 		Proxy:                 *arguments.Proxy.Value,
 		MaxIdleConnections:    *arguments.MaxIdleConnections.Value,
 		IdleConnTimeout:       *arguments.IdleConnTimeout.Value,
@@ -164,6 +523,31 @@ func getCLIParameters() tester.Parameters {
 		CustomHeaders:         *arguments.CustomHeaders.Value,
 		TimeLimit:             *arguments.TimeLimit.Value,
 		ExitWithErrorOnCode:   *arguments.ExitWithErrorOnCode.Value,
+		GracePeriod:           *arguments.GracePeriod.Value,
+		WarmupRequests:        warmupRequests,
+		WarmupDuration:        warmupDuration,
+		Percentiles:           percentiles,
+		TimelineInterval:      *arguments.TimelineInterval.Value,
+		SortBy:                *arguments.SortBy.Value,
+		Assertions:            assertions,
+		Thresholds:            *arguments.Thresholds.Value,
+		RawLogFile:            *arguments.RawLogFile.Value,
+		PrometheusAddress:     *arguments.PrometheusAddress.Value,
+		InfluxUrl:             *arguments.InfluxUrl.Value,
+		StatsdAddress:         *arguments.StatsdAddress.Value,
+		GraphiteAddress:       *arguments.GraphiteAddress.Value,
+		ExportInterval:        *arguments.ExportInterval.Value,
+		RunId:                 *arguments.RunId.Value,
+		OtlpUrl:               *arguments.OtlpUrl.Value,
+		SlowestRequests:       *arguments.SlowestRequests.Value,
+		FastestRequests:       *arguments.FastestRequests.Value,
+		DumpRequests:          *arguments.DumpRequests.Value,
+		DumpSample:            *arguments.DumpSample.Value,
+		DumpFile:              *arguments.DumpFile.Value,
+		DumpBodySize:          *arguments.DumpBodySize.Value,
+		OutputFile:            *arguments.OutputFile.Value,
+		OutputAppend:          *arguments.OutputAppend.Value,
+		OutputHistograms:      *arguments.OutputHistograms.Value,
 	}
 }
 
@@ -175,6 +559,15 @@ func correctNumberOfRequests(parameters *tester.Parameters) {
 	parameters.Requests = len(parameters.Resources) * parameters.Requests
 }
 
+func applyStages(parameters *tester.Parameters) {
+	if len(parameters.Stages) == 0 {
+		return
+	}
+
+	parameters.TimeLimit = tester.StagesDuration(parameters.Stages)
+	parameters.Concurrency = tester.MaxStageTarget(parameters.Stages)
+}
+
 func getUrlsFromFile(fileName string) ([]string, error) {
 	file, err := os.Open(fileName)
 
@@ -199,6 +592,22 @@ func getUrlsFromFile(fileName string) ([]string, error) {
 	return urls, nil
 }
 
+// newDumper returns the writer of the verbose mode, or nil if it is off. The dumps written
+// to the standard error erase the progress bar first, it is redrawn with the next progress update.
+func newDumper(parameters tester.Parameters, bar *progressbar.ProgressBar) (*dump.Writer, error) {
+	if parameters.DumpRequests == 0 && parameters.DumpSample == 0 {
+		return nil, nil
+	}
+
+	var clear func()
+
+	if bar != nil {
+		clear = func() { _ = bar.Clear() }
+	}
+
+	return dump.New(parameters.DumpFile, clear)
+}
+
 func buildProgressBar(parameters tester.Parameters) *progressbar.ProgressBar {
 	progressBarMax := parameters.Requests
 
@@ -217,7 +626,22 @@ func showGreetings(parameters tester.Parameters) {
 	fmt.Printf("%s %s\n", app.ExecutableName, app.VersionString)
 	fmt.Printf("Copyright %d Vasyl Pominchuk\n", time.Now().Year())
 
-	if parameters.TimeLimit > 0 {
+	if len(parameters.Stages) > 0 {
+		fmt.Printf(
+			"Performing [%s] requests in %d load stages with up to %d concurrent requests during %s\n",
+			parameters.Method,
+			len(parameters.Stages),
+			parameters.Concurrency,
+			parameters.TimeLimit,
+		)
+	} else if parameters.Rate > 0 {
+		fmt.Printf(
+			"Performing [%s] requests at a rate of %.2f/s with up to %d requests in flight\n",
+			parameters.Method,
+			parameters.Rate,
+			parameters.Concurrency,
+		)
+	} else if parameters.TimeLimit > 0 {
 		fmt.Printf(
 			"Performing [%s] requests with concurrency level of %d with time limit of %s\n",
 			parameters.Method,
@@ -233,5 +657,19 @@ func showGreetings(parameters tester.Parameters) {
 		)
 	}
 
+	if parameters.WarmupRequests > 0 {
+		fmt.Printf("Warming up with %d requests first\n", parameters.WarmupRequests)
+	} else if parameters.WarmupDuration > 0 {
+		fmt.Printf("Warming up for %s first\n", parameters.WarmupDuration)
+	}
+
+	if parameters.PrometheusAddress != "" {
+		fmt.Printf("Serving Prometheus metrics at http://%s%s\n", parameters.PrometheusAddress, prometheus.MetricsPath)
+	}
+
+	if parameters.OtlpUrl != "" {
+		fmt.Printf("Exporting request spans to %s\n", otlp.TracesUrl(parameters.OtlpUrl))
+	}
+
 	fmt.Printf("\n")
 }
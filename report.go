@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"github.com/vpominchuk/wmetrics/src/app"
+	commandLine "github.com/vpominchuk/wmetrics/src/args"
+	"github.com/vpominchuk/wmetrics/src/rawlog"
+	"github.com/vpominchuk/wmetrics/src/statistics"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"github.com/vpominchuk/wmetrics/src/thresholds"
+	"log"
+	"os"
+	"slices"
+	"time"
+)
+
+// reportWindow selects the results of the raw result log the report is built from
+type reportWindow struct {
+	from, to time.Duration
+	urls     []string
+
+	// start and end of the test, warm-up excluded
+	start, end time.Time
+}
+
+// runReport rebuilds the statistics from a raw result log (-rl) without sending any requests
+func runReport(commandLineArguments []string) {
+	arguments, files := commandLine.GetReportArguments(commandLineArguments)
+
+	if err := commandLine.ValidateReport(arguments); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(files) != 1 {
+		fmt.Printf("Error: expected one raw result log file, got %d\n", len(files))
+		os.Exit(1)
+	}
+
+	percentiles, _ := commandLine.ParsePercentiles(*arguments.Percentiles.Value)
+
+	window := &reportWindow{
+		from: *arguments.From.Value,
+		to:   *arguments.To.Value,
+		urls: *arguments.Urls.Value,
+	}
+
+	// the first pass finds the start of the test, the time window is relative to it
+	run, err := rawlog.Read(files[0], window.measure)
+
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+
+	collector := statistics.NewCollector(
+		statistics.Options{
+			Stages:           run.Stages,
+			Assertions:       run.Assertions,
+			Percentiles:      percentiles,
+			TimelineInterval: *arguments.TimelineInterval.Value,
+			Histograms:       exportsHistograms(*arguments.OutputFormat.Value, *arguments.OutputHistograms.Value),
+			SlowestRequests:  *arguments.SlowestRequests.Value,
+			FastestRequests:  *arguments.FastestRequests.Value,
+		},
+	)
+
+	collector.RunStarted(window.start.Add(window.from))
+
+	_, err = rawlog.Read(
+		files[0], func(result tester.MeasurementResult) {
+			if window.includes(result) {
+				collector.Consume(result)
+			}
+		},
+	)
+
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+
+	if collector.TotalRequests() == 0 {
+		log.Fatalf("Error: no results in the selected time window\n")
+	}
+
+	stat, _ := collector.GetStatistics(window.duration())
+
+	thresholds.Evaluate(parseThresholds(run.Thresholds), stat)
+
+	parameters := tester.Parameters{
+		OutputFormat: *arguments.OutputFormat.Value,
+		SortBy:       *arguments.SortBy.Value,
+	}
+
+	if canPrintGreetings(parameters.OutputFormat) {
+		fmt.Printf("%s %s\n", app.ExecutableName, app.VersionString)
+		fmt.Printf("Report of %s\n\n", files[0])
+	}
+
+	printResults(parameters, stat)
+
+	if thresholds.Failed(stat) {
+		os.Exit(exitCodeThresholdsFailed)
+	}
+}
+
+func (window *reportWindow) measure(result tester.MeasurementResult) {
+	if result.RequestResult.Dispatch.Warmup {
+		return
+	}
+
+	startedAt := result.StartedAt()
+	completedAt := result.CompletedAt()
+
+	if window.start.IsZero() || startedAt.Before(window.start) {
+		window.start = startedAt
+	}
+
+	if completedAt.After(window.end) {
+		window.end = completedAt
+	}
+}
+
+// includes selects the results by URL and completion time. Warm-up results are included if the time window is not set.
+func (window *reportWindow) includes(result tester.MeasurementResult) bool {
+	if len(window.urls) > 0 && !slices.Contains(window.urls, result.RequestResult.Resource.Url.String()) {
+		return false
+	}
+
+	if result.RequestResult.Dispatch.Warmup {
+		return window.from == 0 && window.to == 0
+	}
+
+	offset := result.CompletedAt().Sub(window.start)
+
+	return offset >= window.from && (window.to == 0 || offset < window.to)
+}
+
+func (window *reportWindow) duration() time.Duration {
+	end := window.end
+
+	if window.to > 0 && window.start.Add(window.to).Before(end) {
+		end = window.start.Add(window.to)
+	}
+
+	return end.Sub(window.start.Add(window.from))
+}
@@ -30,6 +30,13 @@ type intArgument struct {
 	Value        *int
 }
 
+type floatArgument struct {
+	Name         string
+	help         string
+	defaultValue float64
+	Value        *float64
+}
+
 type durationArgument struct {
 	Name         string
 	help         string
@@ -47,11 +54,15 @@ type boolArgument struct {
 type Arguments struct {
 	Requests              intArgument
 	Concurrency           intArgument
+	Rate                  stringArgument
+	Stages                stringArgument
+	StagesFile            stringArgument
 	Timeout               durationArgument
 	Method                stringArgument
 	UserAgent             stringArgument
 	UserAgentTemplate     stringArgument
 	KeepAlive             boolArgument
+	ConnectionPool        stringArgument
 	Proxy                 stringArgument
 	MaxIdleConnections    intArgument
 	IdleConnTimeout       durationArgument
@@ -69,6 +80,30 @@ type Arguments struct {
 	TimeLimit             durationArgument
 	URLListFile           stringArgument
 	ExitWithErrorOnCode   stringArrayArgument
+	GracePeriod           durationArgument
+	Warmup                stringArgument
+	Percentiles           stringArgument
+	TimelineInterval      durationArgument
+	SortBy                stringArgument
+	Assertions            stringArrayArgument
+	Thresholds            stringArrayArgument
+	RawLogFile            stringArgument
+	PrometheusAddress     stringArgument
+	InfluxUrl             stringArgument
+	StatsdAddress         stringArgument
+	GraphiteAddress       stringArgument
+	ExportInterval        durationArgument
+	RunId                 stringArgument
+	OtlpUrl               stringArgument
+	SlowestRequests       intArgument
+	FastestRequests       intArgument
+	DumpRequests          intArgument
+	DumpSample            intArgument
+	DumpFile              stringArgument
+	DumpBodySize          intArgument
+	OutputFile            stringArgument
+	OutputAppend          boolArgument
+	OutputHistograms      boolArgument
 }
 
 type multipleStringValues []string
@@ -93,6 +128,23 @@ var arguments = Arguments{
 		help: "Number of multiple `requests` to make at a time",
 	},
 
+	Rate: stringArgument{
+		Name: "rate", defaultValue: "",
+		help: "Send requests at a constant `rate` (100/s, 6000/m, 5/100ms, ...) regardless of response times. " +
+			"-c limits the number of requests in flight, requests due while all of them are in flight are reported as missed",
+	},
+
+	Stages: stringArgument{
+		Name: "st", defaultValue: "",
+		help: "Load `stages`, comma separated list of duration:concurrency pairs. " +
+			"Example: 60s:200,5m:200,30s:0 ramps up to 200 workers over 60s, holds 5m and ramps down over 30s",
+	},
+
+	StagesFile: stringArgument{
+		Name: "sf", defaultValue: "",
+		help: "Load stages `file`, one duration:concurrency pair per line",
+	},
+
 	Timeout: durationArgument{
 		Name: "s", defaultValue: 30 * time.Second,
 		help: "`time` (30s, 800ms, ...) to max. wait for each response",
@@ -118,6 +170,12 @@ var arguments = Arguments{
 		help: "Use HTTP KeepAlive feature",
 	},
 
+	ConnectionPool: stringArgument{
+		Name: "kp", defaultValue: "shared",
+		help: "KeepAlive connection `pool`. Allowed values (shared, worker). " +
+			"shared - all workers use one pool, worker - every worker has its own pool",
+	},
+
 	MaxIdleConnections: intArgument{
 		Name: "km", defaultValue: 100,
 		help: "Max idle `connections`",
@@ -180,7 +238,7 @@ var arguments = Arguments{
 
 	OutputFormat: stringArgument{
 		Name: "O", defaultValue: "std",
-		help: "Output `format`. Allowed values (std, text, json, json-pretty)",
+		help: "Output `format`. Allowed values (std, text, json, json-pretty, csv, tsv, html)",
 	},
 
 	CustomHeaders: stringArrayArgument{
@@ -204,6 +262,135 @@ var arguments = Arguments{
 		Name: "e", defaultValue: nil,
 		help: "Exit with error on HTTP `code`. Multiple codes can be provided with multiple -e flags. Example: -e 403 -e 3xx.",
 	},
+
+	Assertions: stringArrayArgument{
+		Name: "A", defaultValue: nil,
+		help: "Response `assertion`, a failed assertion fails the request. Allowed assertions: status:200,3xx; " +
+			"header:Name[=value]; body-contains:text; body-regex:expression; json:path.0.field[=value]; time:300ms. " +
+			"Multiple assertions can be provided with multiple -A flags.",
+	},
+
+	Thresholds: stringArrayArgument{
+		Name: "th", defaultValue: nil,
+		help: "Performance `threshold` checked against every URL, e.g. p95<250ms, ttfb.p99<100ms, error_rate<1%, rps>500. " +
+			"Prefix all: checks the statistics of all URLs. Multiple thresholds can be provided with multiple -th flags.",
+	},
+
+	RawLogFile: stringArgument{
+		Name: "rl", defaultValue: "",
+		help: "Write every request result to the `file` as JSON Lines, one JSON object per request after the settings of the test",
+	},
+
+	PrometheusAddress: stringArgument{
+		Name: "pm", defaultValue: "",
+		help: "Serve live metrics in the Prometheus text format at the `address` (:9100, 127.0.0.1:9100, ...) " +
+			"under /metrics while the test runs",
+	},
+
+	InfluxUrl: stringArgument{
+		Name: "influx", defaultValue: "",
+		help: "Push the metrics in the InfluxDB line protocol to the write `URL`, " +
+			"e.g. http://localhost:8086/write?db=wmetrics or http://localhost:8086/api/v2/write?org=org&bucket=wmetrics",
+	},
+
+	StatsdAddress: stringArgument{
+		Name: "statsd", defaultValue: "",
+		help: "Push the metrics as StatsD gauges with DogStatsD tags over UDP to the `address` (localhost:8125)",
+	},
+
+	GraphiteAddress: stringArgument{
+		Name: "graphite", defaultValue: "",
+		help: "Push the metrics as tagged series in the Graphite plaintext protocol over TCP " +
+			"to the `address` (localhost:2003)",
+	},
+
+	ExportInterval: durationArgument{
+		Name: "ei", defaultValue: 10 * time.Second,
+		help: "Export `interval` of the metrics pushed to -influx, -statsd and -graphite. " +
+			"The summary of the test is pushed at the end",
+	},
+
+	RunId: stringArgument{
+		Name: "rid", defaultValue: "",
+		help: "Run `id` tag of the pushed metrics (default the start time of the test, e.g. 20240131-154500)",
+	},
+
+	OtlpUrl: stringArgument{
+		Name: "otlp", defaultValue: "",
+		help: "Export a client span of every request in the OTLP/HTTP JSON encoding to the collector `URL` " +
+			"(http://localhost:4318), /v1/traces is added to a URL without a path",
+	},
+
+	SlowestRequests: intArgument{
+		Name: "sn", defaultValue: 0,
+		help: "Number of the slowest `requests` per URL listed in the results with their connection phases and trace ids",
+	},
+
+	FastestRequests: intArgument{
+		Name: "fn", defaultValue: 0,
+		help: "Number of the fastest successful `requests` per URL listed in the results with their connection phases",
+	},
+
+	DumpRequests: intArgument{
+		Name: "v", defaultValue: 0,
+		help: "Verbose mode, dump the request, the response and the connection events of the first `requests`",
+	},
+
+	DumpSample: intArgument{
+		Name: "vs", defaultValue: 0,
+		help: "Verbose mode, dump every `K`-th request with its response and connection events",
+	},
+
+	DumpFile: stringArgument{
+		Name: "vo", defaultValue: "",
+		help: "Write the dumps of the verbose mode to the `file` instead of the standard error",
+	},
+
+	DumpBodySize: intArgument{
+		Name: "vb", defaultValue: 1024,
+		help: "Maximum number of `bytes` of the request and response bodies in the dumps of the verbose mode",
+	},
+
+	OutputFile: stringArgument{
+		Name: "o", defaultValue: "",
+		help: "Write the results to the `file` instead of the standard output",
+	},
+
+	OutputAppend: boolArgument{
+		Name: "oa", defaultValue: false,
+		help: "Append the results to the output file (-o). The csv and tsv rows are appended only under the same header",
+	},
+
+	OutputHistograms: boolArgument{
+		Name: "oh", defaultValue: false,
+		help: "Include the histogram buckets of every timing phase in the json output",
+	},
+
+	Warmup: stringArgument{
+		Name: "w", defaultValue: "",
+		help: "Warm-up `period`, either a number of requests (100) or a time (10s, 500ms, ...). " +
+			"Warm-up requests are excluded from the statistics",
+	},
+
+	Percentiles: stringArgument{
+		Name: "p", defaultValue: "50,90,95,99,99.9",
+		help: "Comma separated list of `percentiles` to calculate for every timing phase",
+	},
+
+	TimelineInterval: durationArgument{
+		Name: "ti", defaultValue: time.Second,
+		help: "Timeline `interval` (1s, 500ms, ...). Requests are grouped into intervals by their completion time, 0 disables the timeline",
+	},
+
+	SortBy: stringArgument{
+		Name: "sb", defaultValue: "median",
+		help: "Sort the comparison table of multiple URLs by the time per request `percentile`. Allowed values (median, p95)",
+	},
+
+	GracePeriod: durationArgument{
+		Name: "gt", defaultValue: 5 * time.Second,
+		help: "Grace `time` (5s, 800ms, ...) for requests in flight when the test is interrupted with Ctrl+C",
+	},
 }
 
 func (arguments *Arguments) init() {
@@ -215,6 +402,18 @@ func (arguments *Arguments) init() {
 		arguments.Concurrency.Name, arguments.Concurrency.defaultValue, arguments.Concurrency.help,
 	)
 
+	arguments.Rate.Value = flag.String(
+		arguments.Rate.Name, arguments.Rate.defaultValue, arguments.Rate.help,
+	)
+
+	arguments.Stages.Value = flag.String(
+		arguments.Stages.Name, arguments.Stages.defaultValue, arguments.Stages.help,
+	)
+
+	arguments.StagesFile.Value = flag.String(
+		arguments.StagesFile.Name, arguments.StagesFile.defaultValue, arguments.StagesFile.help,
+	)
+
 	arguments.Timeout.Value = flag.Duration(
 		arguments.Timeout.Name, arguments.Timeout.defaultValue, arguments.Timeout.help,
 	)
@@ -235,6 +434,10 @@ func (arguments *Arguments) init() {
 		arguments.KeepAlive.Name, arguments.KeepAlive.defaultValue, arguments.KeepAlive.help,
 	)
 
+	arguments.ConnectionPool.Value = flag.String(
+		arguments.ConnectionPool.Name, arguments.ConnectionPool.defaultValue, arguments.ConnectionPool.help,
+	)
+
 	arguments.Proxy.Value = flag.String(
 		arguments.Proxy.Name, arguments.Proxy.defaultValue, arguments.Proxy.help,
 	)
@@ -312,6 +515,119 @@ func (arguments *Arguments) init() {
 	flag.Var(&exitWithErrorOnCode, arguments.ExitWithErrorOnCode.Name, arguments.ExitWithErrorOnCode.help)
 	arguments.ExitWithErrorOnCode.Value = (*[]string)(&exitWithErrorOnCode)
 
+	var assertions multipleStringValues
+	flag.Var(&assertions, arguments.Assertions.Name, arguments.Assertions.help)
+	arguments.Assertions.Value = (*[]string)(&assertions)
+
+	var thresholds multipleStringValues
+	flag.Var(&thresholds, arguments.Thresholds.Name, arguments.Thresholds.help)
+	arguments.Thresholds.Value = (*[]string)(&thresholds)
+
+	arguments.RawLogFile.Value = flag.String(
+		arguments.RawLogFile.Name, arguments.RawLogFile.defaultValue,
+		arguments.RawLogFile.help,
+	)
+
+	arguments.PrometheusAddress.Value = flag.String(
+		arguments.PrometheusAddress.Name, arguments.PrometheusAddress.defaultValue,
+		arguments.PrometheusAddress.help,
+	)
+
+	arguments.InfluxUrl.Value = flag.String(
+		arguments.InfluxUrl.Name, arguments.InfluxUrl.defaultValue,
+		arguments.InfluxUrl.help,
+	)
+
+	arguments.StatsdAddress.Value = flag.String(
+		arguments.StatsdAddress.Name, arguments.StatsdAddress.defaultValue,
+		arguments.StatsdAddress.help,
+	)
+
+	arguments.GraphiteAddress.Value = flag.String(
+		arguments.GraphiteAddress.Name, arguments.GraphiteAddress.defaultValue,
+		arguments.GraphiteAddress.help,
+	)
+
+	arguments.ExportInterval.Value = flag.Duration(
+		arguments.ExportInterval.Name, arguments.ExportInterval.defaultValue,
+		arguments.ExportInterval.help,
+	)
+
+	arguments.RunId.Value = flag.String(
+		arguments.RunId.Name, arguments.RunId.defaultValue,
+		arguments.RunId.help,
+	)
+
+	arguments.OtlpUrl.Value = flag.String(
+		arguments.OtlpUrl.Name, arguments.OtlpUrl.defaultValue,
+		arguments.OtlpUrl.help,
+	)
+
+	arguments.SlowestRequests.Value = flag.Int(
+		arguments.SlowestRequests.Name, arguments.SlowestRequests.defaultValue, arguments.SlowestRequests.help,
+	)
+
+	arguments.FastestRequests.Value = flag.Int(
+		arguments.FastestRequests.Name, arguments.FastestRequests.defaultValue, arguments.FastestRequests.help,
+	)
+
+	arguments.DumpRequests.Value = flag.Int(
+		arguments.DumpRequests.Name, arguments.DumpRequests.defaultValue, arguments.DumpRequests.help,
+	)
+
+	arguments.DumpSample.Value = flag.Int(
+		arguments.DumpSample.Name, arguments.DumpSample.defaultValue, arguments.DumpSample.help,
+	)
+
+	arguments.DumpFile.Value = flag.String(
+		arguments.DumpFile.Name, arguments.DumpFile.defaultValue,
+		arguments.DumpFile.help,
+	)
+
+	arguments.DumpBodySize.Value = flag.Int(
+		arguments.DumpBodySize.Name, arguments.DumpBodySize.defaultValue, arguments.DumpBodySize.help,
+	)
+
+	arguments.OutputFile.Value = flag.String(
+		arguments.OutputFile.Name, arguments.OutputFile.defaultValue,
+		arguments.OutputFile.help,
+	)
+
+	arguments.OutputAppend.Value = flag.Bool(
+		arguments.OutputAppend.Name, arguments.OutputAppend.defaultValue,
+		arguments.OutputAppend.help,
+	)
+
+	arguments.OutputHistograms.Value = flag.Bool(
+		arguments.OutputHistograms.Name, arguments.OutputHistograms.defaultValue,
+		arguments.OutputHistograms.help,
+	)
+
+	arguments.GracePeriod.Value = flag.Duration(
+		arguments.GracePeriod.Name, arguments.GracePeriod.defaultValue,
+		arguments.GracePeriod.help,
+	)
+
+	arguments.Warmup.Value = flag.String(
+		arguments.Warmup.Name, arguments.Warmup.defaultValue,
+		arguments.Warmup.help,
+	)
+
+	arguments.Percentiles.Value = flag.String(
+		arguments.Percentiles.Name, arguments.Percentiles.defaultValue,
+		arguments.Percentiles.help,
+	)
+
+	arguments.TimelineInterval.Value = flag.Duration(
+		arguments.TimelineInterval.Name, arguments.TimelineInterval.defaultValue,
+		arguments.TimelineInterval.help,
+	)
+
+	arguments.SortBy.Value = flag.String(
+		arguments.SortBy.Name, arguments.SortBy.defaultValue,
+		arguments.SortBy.help,
+	)
+
 	flag.Usage = customUsage
 
 	flag.Parse()
@@ -330,14 +646,20 @@ func customUsage() {
 	fmt.Fprintf(
 		flag.CommandLine.Output(), "Usage: %s [options] URL_LIST\n", app.ExecutableName,
 	)
+	fmt.Fprintf(
+		flag.CommandLine.Output(), "       %s report [options] RAW_LOG_FILE\n", app.ExecutableName,
+	)
+	fmt.Fprintf(
+		flag.CommandLine.Output(), "       %s compare [options] BASELINE_JSON CURRENT_JSON\n", app.ExecutableName,
+	)
 	fmt.Fprint(flag.CommandLine.Output(), "Options are:\n")
-	customPrintDefaults()
+	customPrintDefaults(flag.CommandLine)
 	fmt.Fprintf(flag.CommandLine.Output(), "\nVersion: %s\n", app.VersionString)
 }
 
-func customPrintDefaults() {
+func customPrintDefaults(flags *flag.FlagSet) {
 	strLength := 30
-	flag.VisitAll(
+	flags.VisitAll(
 		func(f *flag.Flag) {
 			name, usage := flag.UnquoteUsage(f)
 
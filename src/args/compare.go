@@ -0,0 +1,89 @@
+package args
+
+import (
+	"flag"
+	"fmt"
+	"github.com/vpominchuk/wmetrics/src/app"
+	"slices"
+)
+
+// CompareArguments are the options of the compare command which compares two json outputs
+type CompareArguments struct {
+	LatencyTolerance   floatArgument
+	RpsTolerance       floatArgument
+	ErrorRateTolerance floatArgument
+	OutputFormat       stringArgument
+}
+
+var compareArguments = CompareArguments{
+	LatencyTolerance: floatArgument{
+		Name: "lt", defaultValue: 10,
+		help: "Latency `tolerance`, the mean, median and percentiles may grow by this percent of the baseline",
+	},
+
+	RpsTolerance: floatArgument{
+		Name: "rt", defaultValue: 10,
+		help: "Requests per second `tolerance`, the rps may drop by this percent of the baseline",
+	},
+
+	ErrorRateTolerance: floatArgument{
+		Name: "et", defaultValue: 1,
+		help: "Error rate `tolerance`, the error rate may grow by this number of percentage points",
+	},
+
+	OutputFormat: stringArgument{
+		Name: "O", defaultValue: "std",
+		help: "Output `format`. Allowed values (std, text, json, json-pretty)",
+	},
+}
+
+func (arguments *CompareArguments) init(flags *flag.FlagSet) {
+	arguments.LatencyTolerance.Value = flags.Float64(
+		arguments.LatencyTolerance.Name, arguments.LatencyTolerance.defaultValue, arguments.LatencyTolerance.help,
+	)
+
+	arguments.RpsTolerance.Value = flags.Float64(
+		arguments.RpsTolerance.Name, arguments.RpsTolerance.defaultValue, arguments.RpsTolerance.help,
+	)
+
+	arguments.ErrorRateTolerance.Value = flags.Float64(
+		arguments.ErrorRateTolerance.Name, arguments.ErrorRateTolerance.defaultValue,
+		arguments.ErrorRateTolerance.help,
+	)
+
+	arguments.OutputFormat.Value = flags.String(
+		arguments.OutputFormat.Name, arguments.OutputFormat.defaultValue, arguments.OutputFormat.help,
+	)
+}
+
+// GetCompareArguments parses the options of the compare command, commandLine does not include the command name
+func GetCompareArguments(commandLine []string) (CompareArguments, []string) {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	compareArguments.init(flags)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s compare [options] BASELINE_JSON CURRENT_JSON\n", app.ExecutableName)
+		fmt.Fprint(flags.Output(), "Compares two json outputs (-O json). Options are:\n")
+		customPrintDefaults(flags)
+	}
+
+	_ = flags.Parse(commandLine)
+
+	return compareArguments, flags.Args()
+}
+
+func ValidateCompare(arguments CompareArguments) error {
+	if *arguments.LatencyTolerance.Value < 0 || *arguments.RpsTolerance.Value < 0 ||
+		*arguments.ErrorRateTolerance.Value < 0 {
+		return fmt.Errorf("tolerance cannot be negative")
+	}
+
+	if !slices.Contains(allowedCompareOutputFormats, *arguments.OutputFormat.Value) {
+		return fmt.Errorf(
+			"invalid output format: %s. Allowed formats are: %v", *arguments.OutputFormat.Value,
+			allowedCompareOutputFormats,
+		)
+	}
+
+	return nil
+}
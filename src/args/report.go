@@ -0,0 +1,151 @@
+package args
+
+import (
+	"flag"
+	"fmt"
+	"github.com/vpominchuk/wmetrics/src/app"
+	"slices"
+)
+
+// ReportArguments are the options of the report command which rebuilds the statistics from a raw result log
+type ReportArguments struct {
+	From             durationArgument
+	To               durationArgument
+	Urls             stringArrayArgument
+	Percentiles      stringArgument
+	OutputFormat     stringArgument
+	OutputHistograms boolArgument
+	TimelineInterval durationArgument
+	SortBy           stringArgument
+	SlowestRequests  intArgument
+	FastestRequests  intArgument
+}
+
+var reportArguments = ReportArguments{
+	From: durationArgument{
+		Name: "from", defaultValue: 0,
+		help: "Include the requests completed after `time` (30s, 2m, ...) from the start of the test",
+	},
+
+	To: durationArgument{
+		Name: "to", defaultValue: 0,
+		help: "Include the requests completed before `time` (30s, 2m, ...) from the start of the test",
+	},
+
+	Urls: stringArrayArgument{
+		Name: "url", defaultValue: nil,
+		help: "Include the requests to the `URL` only. Multiple URLs can be provided with multiple -url flags.",
+	},
+
+	Percentiles: arguments.Percentiles,
+
+	OutputFormat: arguments.OutputFormat,
+
+	OutputHistograms: arguments.OutputHistograms,
+
+	TimelineInterval: arguments.TimelineInterval,
+
+	SortBy: arguments.SortBy,
+
+	SlowestRequests: arguments.SlowestRequests,
+
+	FastestRequests: arguments.FastestRequests,
+}
+
+func (arguments *ReportArguments) init(flags *flag.FlagSet) {
+	arguments.From.Value = flags.Duration(
+		arguments.From.Name, arguments.From.defaultValue, arguments.From.help,
+	)
+
+	arguments.To.Value = flags.Duration(
+		arguments.To.Name, arguments.To.defaultValue, arguments.To.help,
+	)
+
+	var urls multipleStringValues
+	flags.Var(&urls, arguments.Urls.Name, arguments.Urls.help)
+	arguments.Urls.Value = (*[]string)(&urls)
+
+	arguments.Percentiles.Value = flags.String(
+		arguments.Percentiles.Name, arguments.Percentiles.defaultValue, arguments.Percentiles.help,
+	)
+
+	arguments.OutputFormat.Value = flags.String(
+		arguments.OutputFormat.Name, arguments.OutputFormat.defaultValue, arguments.OutputFormat.help,
+	)
+
+	arguments.OutputHistograms.Value = flags.Bool(
+		arguments.OutputHistograms.Name, arguments.OutputHistograms.defaultValue, arguments.OutputHistograms.help,
+	)
+
+	arguments.TimelineInterval.Value = flags.Duration(
+		arguments.TimelineInterval.Name, arguments.TimelineInterval.defaultValue, arguments.TimelineInterval.help,
+	)
+
+	arguments.SortBy.Value = flags.String(
+		arguments.SortBy.Name, arguments.SortBy.defaultValue, arguments.SortBy.help,
+	)
+
+	arguments.SlowestRequests.Value = flags.Int(
+		arguments.SlowestRequests.Name, arguments.SlowestRequests.defaultValue, arguments.SlowestRequests.help,
+	)
+
+	arguments.FastestRequests.Value = flags.Int(
+		arguments.FastestRequests.Name, arguments.FastestRequests.defaultValue, arguments.FastestRequests.help,
+	)
+}
+
+// GetReportArguments parses the options of the report command, commandLine does not include the command name
+func GetReportArguments(commandLine []string) (ReportArguments, []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	reportArguments.init(flags)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s report [options] RAW_LOG_FILE\n", app.ExecutableName)
+		fmt.Fprint(flags.Output(), "Rebuilds the statistics from a raw result log (-rl). Options are:\n")
+		customPrintDefaults(flags)
+	}
+
+	_ = flags.Parse(commandLine)
+
+	return reportArguments, flags.Args()
+}
+
+func ValidateReport(arguments ReportArguments) error {
+	if *arguments.From.Value < 0 || *arguments.To.Value < 0 {
+		return fmt.Errorf("time window cannot be negative")
+	}
+
+	if *arguments.To.Value > 0 && *arguments.To.Value <= *arguments.From.Value {
+		return fmt.Errorf("end of the time window must be after its start")
+	}
+
+	percentiles, err := ParsePercentiles(*arguments.Percentiles.Value)
+
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(allowedOutputFormats, *arguments.OutputFormat.Value) {
+		return fmt.Errorf(
+			"invalid output format: %s. Allowed formats are: %v", *arguments.OutputFormat.Value, allowedOutputFormats,
+		)
+	}
+
+	if *arguments.TimelineInterval.Value < 0 {
+		return fmt.Errorf("timeline interval cannot be negative")
+	}
+
+	if !slices.Contains(allowedSortBy, *arguments.SortBy.Value) {
+		return fmt.Errorf("invalid sort by: %s. Allowed values are: %v", *arguments.SortBy.Value, allowedSortBy)
+	}
+
+	if *arguments.SlowestRequests.Value < 0 || *arguments.FastestRequests.Value < 0 {
+		return fmt.Errorf("number of the slowest and fastest requests must be 0 or greater")
+	}
+
+	if *arguments.SortBy.Value == "p95" && !slices.Contains(percentiles, 95) {
+		return fmt.Errorf("sort by p95 requires the 95th percentile to be calculated, add it to -%s", arguments.Percentiles.Name)
+	}
+
+	return nil
+}
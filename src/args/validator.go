@@ -4,12 +4,24 @@ import (
 	"fmt"
 	"github.com/vpominchuk/wmetrics/src/app"
 	"github.com/vpominchuk/wmetrics/src/formatter"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"github.com/vpominchuk/wmetrics/src/thresholds"
+	"net"
+	"net/url"
 	"os"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
+var allowedOutputFormats = []string{"std", "text", "json", "json-pretty", "csv", "tsv", "html"}
+
+var allowedCompareOutputFormats = []string{"std", "text", "json", "json-pretty"}
+
+var allowedSortBy = []string{"median", "p95"}
+
 func Validate(arguments Arguments) error {
 	postDataSources := getPostDataSourcesCount(arguments)
 
@@ -17,10 +29,71 @@ func Validate(arguments Arguments) error {
 		return fmt.Errorf("post data can only be specified once")
 	}
 
-	if *arguments.TimeLimit.Value == 0 && *arguments.Requests.Value < *arguments.Concurrency.Value {
+	stages, err := GetStages(arguments)
+
+	if err != nil {
+		return err
+	}
+
+	if len(stages) > 0 {
+		if *arguments.Rate.Value != "" {
+			return fmt.Errorf("load stages cannot be used together with rate")
+		}
+
+		if *arguments.TimeLimit.Value > 0 {
+			return fmt.Errorf("load stages cannot be used together with time limit")
+		}
+	}
+
+	if len(stages) == 0 && *arguments.TimeLimit.Value == 0 && *arguments.Requests.Value < *arguments.Concurrency.Value {
 		return fmt.Errorf("cannot use concurrency level greater than total number of requests")
 	}
 
+	if _, err := ParseRate(*arguments.Rate.Value); err != nil {
+		return err
+	}
+
+	if _, _, err := ParseWarmup(*arguments.Warmup.Value); err != nil {
+		return err
+	}
+
+	percentiles, err := ParsePercentiles(*arguments.Percentiles.Value)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := ParseAssertions(*arguments.Assertions.Value); err != nil {
+		return err
+	}
+
+	for _, expression := range *arguments.Thresholds.Value {
+		threshold, err := thresholds.Parse(expression)
+
+		if err != nil {
+			return err
+		}
+
+		if percentile := threshold.Percentile(); percentile > 0 && !slices.Contains(percentiles, percentile) {
+			return fmt.Errorf(
+				"threshold %s requires the %s percentile to be calculated, add it to -%s",
+				expression, threshold.Metric, arguments.Percentiles.Name,
+			)
+		}
+	}
+
+	if !slices.Contains(allowedSortBy, *arguments.SortBy.Value) {
+		return fmt.Errorf("invalid sort by: %s. Allowed values are: %v", *arguments.SortBy.Value, allowedSortBy)
+	}
+
+	if *arguments.SortBy.Value == "p95" && !slices.Contains(percentiles, 95) {
+		return fmt.Errorf("sort by p95 requires the 95th percentile to be calculated, add it to -%s", arguments.Percentiles.Name)
+	}
+
+	if *arguments.TimelineInterval.Value < 0 {
+		return fmt.Errorf("timeline interval cannot be negative")
+	}
+
 	method := strings.ToUpper(*arguments.Method.Value)
 
 	allowedMethods := []string{"GET", "HEAD", "DELETE", "POST", "PUT", "PATCH"}
@@ -28,13 +101,72 @@ func Validate(arguments Arguments) error {
 		return fmt.Errorf("invalid method: %s. Allowed methods are: %v", method, allowedMethods)
 	}
 
-	allowedOutputFormats := []string{"std", "text", "json", "json-pretty"}
 	if !slices.Contains(allowedOutputFormats, *arguments.OutputFormat.Value) {
 		return fmt.Errorf(
 			"invalid output format: %s. Allowed formats are: %v", *arguments.OutputFormat.Value, allowedOutputFormats,
 		)
 	}
 
+	if *arguments.PrometheusAddress.Value != "" {
+		if _, _, err := net.SplitHostPort(*arguments.PrometheusAddress.Value); err != nil {
+			return fmt.Errorf("invalid prometheus address: %v", err)
+		}
+	}
+
+	if *arguments.InfluxUrl.Value != "" {
+		influxUrl, err := url.ParseRequestURI(*arguments.InfluxUrl.Value)
+
+		if err != nil || (influxUrl.Scheme != "http" && influxUrl.Scheme != "https") || influxUrl.Host == "" {
+			return fmt.Errorf("invalid influx url: %s", *arguments.InfluxUrl.Value)
+		}
+	}
+
+	for _, address := range []string{*arguments.StatsdAddress.Value, *arguments.GraphiteAddress.Value} {
+		if address == "" {
+			continue
+		}
+
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("invalid exporter address: %v", err)
+		}
+	}
+
+	if *arguments.ExportInterval.Value <= 0 {
+		return fmt.Errorf("export interval must be greater than 0")
+	}
+
+	if *arguments.OtlpUrl.Value != "" {
+		otlpUrl, err := url.ParseRequestURI(*arguments.OtlpUrl.Value)
+
+		if err != nil || (otlpUrl.Scheme != "http" && otlpUrl.Scheme != "https") || otlpUrl.Host == "" {
+			return fmt.Errorf("invalid otlp url: %s", *arguments.OtlpUrl.Value)
+		}
+	}
+
+	if *arguments.SlowestRequests.Value < 0 || *arguments.FastestRequests.Value < 0 {
+		return fmt.Errorf("number of the slowest and fastest requests must be 0 or greater")
+	}
+
+	if *arguments.DumpRequests.Value < 0 || *arguments.DumpSample.Value < 0 || *arguments.DumpBodySize.Value < 0 {
+		return fmt.Errorf("number of the dumped requests, the sampling and the body size must be 0 or greater")
+	}
+
+	if *arguments.DumpFile.Value != "" && *arguments.DumpRequests.Value == 0 && *arguments.DumpSample.Value == 0 {
+		return fmt.Errorf("dump file requires the verbose mode (-v or -vs)")
+	}
+
+	if *arguments.OutputAppend.Value && *arguments.OutputFile.Value == "" {
+		return fmt.Errorf("appending the results requires an output file (-o)")
+	}
+
+	allowedConnectionPools := []string{"shared", "worker"}
+	if !slices.Contains(allowedConnectionPools, *arguments.ConnectionPool.Value) {
+		return fmt.Errorf(
+			"invalid connection pool: %s. Allowed values are: %v", *arguments.ConnectionPool.Value,
+			allowedConnectionPools,
+		)
+	}
+
 	if arguments.FormData.Value != nil && *arguments.FormData.Value != "" {
 		if arguments.ContentType.Value == nil || *arguments.ContentType.Value == "" {
 			return fmt.Errorf("content type is required for form data. Use -%s", arguments.ContentType.Name)
@@ -90,6 +222,153 @@ func Validate(arguments Arguments) error {
 	return nil
 }
 
+// ParseRate converts a rate like "100/s", "6000/m" or "5/100ms" to requests per second
+func ParseRate(rate string) (float64, error) {
+	if rate == "" {
+		return 0, nil
+	}
+
+	requests, period, found := strings.Cut(rate, "/")
+
+	if !found {
+		period = "1s"
+	}
+
+	count, err := strconv.ParseFloat(requests, 64)
+
+	if err != nil || count <= 0 {
+		return 0, fmt.Errorf("invalid rate: %s", rate)
+	}
+
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+
+	duration, err := time.ParseDuration(period)
+
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid rate period: %s", rate)
+	}
+
+	return count / duration.Seconds(), nil
+}
+
+func ParsePercentiles(value string) ([]float64, error) {
+	percentiles := make([]float64, 0)
+
+	for _, percentileValue := range strings.Split(value, ",") {
+		percentileValue = strings.TrimSpace(percentileValue)
+
+		if percentileValue == "" {
+			continue
+		}
+
+		percentile, err := strconv.ParseFloat(strings.TrimPrefix(percentileValue, "p"), 64)
+
+		if err != nil || percentile <= 0 || percentile > 100 {
+			return nil, fmt.Errorf("invalid percentile: %s. Percentiles must be in range (0, 100]", percentileValue)
+		}
+
+		if !slices.Contains(percentiles, percentile) {
+			percentiles = append(percentiles, percentile)
+		}
+	}
+
+	slices.Sort(percentiles)
+
+	return percentiles, nil
+}
+
+// ParseWarmup converts the warm-up period to either a number of requests or a duration
+func ParseWarmup(warmup string) (int, time.Duration, error) {
+	if warmup == "" {
+		return 0, 0, nil
+	}
+
+	if requests, err := strconv.Atoi(warmup); err == nil {
+		if requests < 0 {
+			return 0, 0, fmt.Errorf("invalid warm-up number of requests: %s", warmup)
+		}
+
+		return requests, 0, nil
+	}
+
+	duration, err := time.ParseDuration(warmup)
+
+	if err != nil || duration < 0 {
+		return 0, 0, fmt.Errorf("invalid warm-up period: %s. Use a number of requests or a time", warmup)
+	}
+
+	return 0, duration, nil
+}
+
+// GetStages reads load stages either from the command line or from the stages file
+func GetStages(arguments Arguments) ([]tester.Stage, error) {
+	stages := *arguments.Stages.Value
+
+	if *arguments.StagesFile.Value != "" {
+		if stages != "" {
+			return nil, fmt.Errorf("load stages can only be specified once")
+		}
+
+		content, err := os.ReadFile(*arguments.StagesFile.Value)
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to read load stages file: %v", err)
+		}
+
+		lines := make([]string, 0)
+
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+
+			if line != "" && !strings.HasPrefix(line, "#") {
+				lines = append(lines, line)
+			}
+		}
+
+		stages = strings.Join(lines, ",")
+	}
+
+	return ParseStages(stages)
+}
+
+func ParseStages(value string) ([]tester.Stage, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	stages := make([]tester.Stage, 0)
+
+	for _, stageValue := range strings.Split(value, ",") {
+		durationValue, targetValue, found := strings.Cut(strings.TrimSpace(stageValue), ":")
+
+		if !found {
+			return nil, fmt.Errorf("invalid load stage: %s. Expected format is duration:concurrency", stageValue)
+		}
+
+		duration, err := time.ParseDuration(strings.TrimSpace(durationValue))
+
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid load stage duration: %s", stageValue)
+		}
+
+		target, err := strconv.Atoi(strings.TrimSpace(targetValue))
+
+		if err != nil || target < 0 {
+			return nil, fmt.Errorf("invalid load stage concurrency: %s", stageValue)
+		}
+
+		stages = append(stages, tester.Stage{Duration: duration, Target: target})
+	}
+
+	if tester.MaxStageTarget(stages) == 0 {
+		return nil, fmt.Errorf("at least one load stage must have concurrency greater than 0")
+	}
+
+	return stages, nil
+}
+
 func validateUrlListFile(urlListFile string) error {
 	if urlListFile == "" {
 		return nil
@@ -135,3 +414,19 @@ func getPostDataSourcesCount(arguments Arguments) int {
 
 	return postDataSources
 }
+
+func ParseAssertions(values []string) ([]tester.Assertion, error) {
+	assertions := make([]tester.Assertion, 0, len(values))
+
+	for _, value := range values {
+		assertion, err := tester.ParseAssertion(value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		assertions = append(assertions, assertion)
+	}
+
+	return assertions, nil
+}
@@ -0,0 +1,120 @@
+package args
+
+import (
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		rate string
+		want float64
+	}{
+		{"", 0},
+		{"100", 100},
+		{"100/s", 100},
+		{"6000/m", 100},
+		{"5/100ms", 50},
+		{"1/2s", 0.5},
+		{"2.5/s", 2.5},
+		{"3600/h", 1},
+	}
+
+	for _, test := range tests {
+		got, err := ParseRate(test.rate)
+
+		if err != nil {
+			t.Errorf("ParseRate(%q) returned an error: %v", test.rate, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("ParseRate(%q) = %g, want %g", test.rate, got, test.want)
+		}
+	}
+}
+
+func TestParseRateInvalid(t *testing.T) {
+	for _, rate := range []string{"fast", "0/s", "-5/s", "10/", "10/0s", "10/week", "/s"} {
+		if _, err := ParseRate(rate); err == nil {
+			t.Errorf("ParseRate(%q) did not return an error", rate)
+		}
+	}
+}
+
+func TestParseStages(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []tester.Stage
+	}{
+		{"", nil},
+		{"10s:5", []tester.Stage{{Duration: 10 * time.Second, Target: 5}}},
+		{
+			"60s:200, 5m:200 ,30s:0",
+			[]tester.Stage{
+				{Duration: time.Minute, Target: 200},
+				{Duration: 5 * time.Minute, Target: 200},
+				{Duration: 30 * time.Second, Target: 0},
+			},
+		},
+		{"500ms:0,1s:3", []tester.Stage{{Duration: 500 * time.Millisecond}, {Duration: time.Second, Target: 3}}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseStages(test.value)
+
+		if err != nil {
+			t.Errorf("ParseStages(%q) returned an error: %v", test.value, err)
+			continue
+		}
+
+		if !slices.Equal(got, test.want) {
+			t.Errorf("ParseStages(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseStagesInvalid(t *testing.T) {
+	for _, value := range []string{"10s", "10s:", "fast:5", "0s:5", "-1s:5", "10s:-1", "10s:many", "10s:0,5s:0", "10s:5,"} {
+		if _, err := ParseStages(value); err == nil {
+			t.Errorf("ParseStages(%q) did not return an error", value)
+		}
+	}
+}
+
+func TestParsePercentiles(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []float64
+	}{
+		{"", []float64{}},
+		{"50,90,95,99,99.9", []float64{50, 90, 95, 99, 99.9}},
+		{"p99, p50 ,95", []float64{50, 95, 99}},
+		{"99,50,99", []float64{50, 99}},
+		{"100,0.1", []float64{0.1, 100}},
+		{"50,,90", []float64{50, 90}},
+	}
+
+	for _, test := range tests {
+		got, err := ParsePercentiles(test.value)
+
+		if err != nil {
+			t.Errorf("ParsePercentiles(%q) returned an error: %v", test.value, err)
+			continue
+		}
+
+		if !slices.Equal(got, test.want) {
+			t.Errorf("ParsePercentiles(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParsePercentilesInvalid(t *testing.T) {
+	for _, value := range []string{"0", "-5", "100.1", "high", "50,p", "95%"} {
+		if _, err := ParsePercentiles(value); err == nil {
+			t.Errorf("ParsePercentiles(%q) did not return an error", value)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package compare
+
+import (
+	"encoding/json"
+	"github.com/vpominchuk/wmetrics/src/statistics"
+	"os"
+	"slices"
+	"time"
+)
+
+const (
+	MetricRequestsPerSecond = "rps"
+	MetricMean              = "mean"
+	MetricMedian            = "median"
+	MetricErrorRate         = "error_rate"
+)
+
+// Tolerances are the changes which are not considered a regression. Latency and RequestsPerSecond
+// are in percent of the baseline, ErrorRate is in percentage points.
+type Tolerances struct {
+	Latency,
+	RequestsPerSecond,
+	ErrorRate float64
+}
+
+// Row compares one metric of a URL. Values are in Unit (ms, %, ...), Change is in percent of the baseline.
+type Row struct {
+	Url      string
+	Metric   string
+	Unit     string
+	Baseline float64
+	Current  float64
+	Delta    float64
+	Change   float64
+
+	Regression bool
+}
+
+type Comparison struct {
+	Rows []Row
+
+	// OnlyBaseline and OnlyCurrent list the URLs which can not be compared
+	OnlyBaseline,
+	OnlyCurrent []string
+}
+
+// Load reads the statistics saved with the json output format
+func Load(fileName string) (statistics.Statistics, error) {
+	data, err := os.ReadFile(fileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var stat statistics.Statistics
+
+	if err := json.Unmarshal(data, &stat); err != nil {
+		return nil, err
+	}
+
+	return stat, nil
+}
+
+func Compare(baseline, current statistics.Statistics, tolerances Tolerances) Comparison {
+	comparison := Comparison{}
+
+	for _, url := range urls(baseline) {
+		currentStat, ok := current[url]
+
+		if !ok {
+			comparison.OnlyBaseline = append(comparison.OnlyBaseline, url)
+			continue
+		}
+
+		comparison.Rows = append(comparison.Rows, compareUrl(url, baseline[url], currentStat, tolerances)...)
+	}
+
+	for _, url := range urls(current) {
+		if _, ok := baseline[url]; !ok {
+			comparison.OnlyCurrent = append(comparison.OnlyCurrent, url)
+		}
+	}
+
+	return comparison
+}
+
+func (comparison Comparison) HasRegression() bool {
+	return slices.ContainsFunc(
+		comparison.Rows, func(row Row) bool {
+			return row.Regression
+		},
+	)
+}
+
+// urls returns the URLs in alphabetical order followed by the statistics of all URLs
+func urls(stat statistics.Statistics) []string {
+	result := stat.Urls()
+
+	if _, ok := stat[statistics.AllUrls]; ok {
+		result = append(result, statistics.AllUrls)
+	}
+
+	return result
+}
+
+func compareUrl(url string, baseline, current statistics.SingleUrlStatistics, tolerances Tolerances) []Row {
+	rows := []Row{
+		newRow(url, MetricRequestsPerSecond, "", requestsPerSecond(baseline), requestsPerSecond(current)),
+		newRow(url, MetricMean, "ms", toMilliseconds(baseline.RequestTimeAvg), toMilliseconds(current.RequestTimeAvg)),
+		newRow(
+			url, MetricMedian, "ms", toMilliseconds(baseline.RequestTimeMedian),
+			toMilliseconds(current.RequestTimeMedian),
+		),
+	}
+
+	baselinePercentiles := baseline.Percentiles[statistics.PhaseTotal]
+	currentPercentiles := current.Percentiles[statistics.PhaseTotal]
+
+	for _, key := range baseline.Percentiles.Keys() {
+		currentValue, ok := currentPercentiles[key]
+
+		if !ok {
+			continue
+		}
+
+		rows = append(
+			rows, newRow(url, key, "ms", toMilliseconds(baselinePercentiles[key]), toMilliseconds(currentValue)),
+		)
+	}
+
+	rows = append(rows, newRow(url, MetricErrorRate, "%", errorRate(baseline), errorRate(current)))
+
+	for index := range rows {
+		rows[index].Regression = rows[index].isRegression(tolerances)
+	}
+
+	return rows
+}
+
+func newRow(url, metric, unit string, baseline, current float64) Row {
+	row := Row{
+		Url:      url,
+		Metric:   metric,
+		Unit:     unit,
+		Baseline: baseline,
+		Current:  current,
+		Delta:    current - baseline,
+	}
+
+	if baseline != 0 {
+		row.Change = row.Delta / baseline * 100
+	}
+
+	return row
+}
+
+func (row Row) isRegression(tolerances Tolerances) bool {
+	switch row.Metric {
+	case MetricRequestsPerSecond:
+		return row.Baseline != 0 && row.Change < -tolerances.RequestsPerSecond
+	case MetricErrorRate:
+		return row.Delta > tolerances.ErrorRate
+	}
+
+	return row.Baseline != 0 && row.Change > tolerances.Latency
+}
+
+func requestsPerSecond(stat statistics.SingleUrlStatistics) float64 {
+	if stat.TotalTime <= 0 {
+		return 0
+	}
+
+	return float64(stat.TotalRequests) / stat.TotalTime.Seconds()
+}
+
+func errorRate(stat statistics.SingleUrlStatistics) float64 {
+	if stat.TotalRequests == 0 {
+		return 0
+	}
+
+	return float64(stat.ErrorRequests) / float64(stat.TotalRequests) * 100
+}
+
+func toMilliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
@@ -0,0 +1,147 @@
+package compare
+
+import (
+	"github.com/vpominchuk/wmetrics/src/statistics"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	baseline := statistics.Statistics{
+		"http://a/": {
+			TotalRequests:     1000,
+			ErrorRequests:     10,
+			TotalTime:         10 * time.Second,
+			RequestTimeAvg:    100 * time.Millisecond,
+			RequestTimeMedian: 80 * time.Millisecond,
+			Percentiles: statistics.Percentiles{
+				statistics.PhaseTotal: {"p50": 80 * time.Millisecond, "p99": 200 * time.Millisecond},
+			},
+		},
+		"http://b/": {TotalRequests: 10, TotalTime: time.Second},
+		statistics.AllUrls: {
+			TotalRequests:  1000,
+			TotalTime:      10 * time.Second,
+			RequestTimeAvg: 100 * time.Millisecond,
+		},
+	}
+
+	current := statistics.Statistics{
+		"http://a/": {
+			TotalRequests:     800,
+			ErrorRequests:     24,
+			TotalTime:         10 * time.Second,
+			RequestTimeAvg:    104 * time.Millisecond,
+			RequestTimeMedian: 100 * time.Millisecond,
+			Percentiles: statistics.Percentiles{
+				statistics.PhaseTotal: {"p50": 100 * time.Millisecond},
+			},
+		},
+		"http://c/": {TotalRequests: 10, TotalTime: time.Second},
+		statistics.AllUrls: {
+			TotalRequests:  1000,
+			TotalTime:      10 * time.Second,
+			RequestTimeAvg: 90 * time.Millisecond,
+		},
+	}
+
+	comparison := Compare(baseline, current, Tolerances{Latency: 5, RequestsPerSecond: 10, ErrorRate: 1})
+
+	if !slices.Equal(comparison.OnlyBaseline, []string{"http://b/"}) {
+		t.Errorf("OnlyBaseline = %v, want [http://b/]", comparison.OnlyBaseline)
+	}
+
+	if !slices.Equal(comparison.OnlyCurrent, []string{"http://c/"}) {
+		t.Errorf("OnlyCurrent = %v, want [http://c/]", comparison.OnlyCurrent)
+	}
+
+	tests := []struct {
+		url        string
+		metric     string
+		baseline   float64
+		current    float64
+		change     float64
+		regression bool
+	}{
+		{"http://a/", MetricRequestsPerSecond, 100, 80, -20, true},
+		{"http://a/", MetricMean, 100, 104, 4, false},
+		{"http://a/", MetricMedian, 80, 100, 25, true},
+		{"http://a/", "p50", 80, 100, 25, true},
+		{"http://a/", MetricErrorRate, 1, 3, 200, true},
+		{statistics.AllUrls, MetricRequestsPerSecond, 100, 100, 0, false},
+		{statistics.AllUrls, MetricMean, 100, 90, -10, false},
+		{statistics.AllUrls, MetricMedian, 0, 0, 0, false},
+		{statistics.AllUrls, MetricErrorRate, 0, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		index := slices.IndexFunc(
+			comparison.Rows, func(row Row) bool {
+				return row.Url == test.url && row.Metric == test.metric
+			},
+		)
+
+		if index < 0 {
+			t.Errorf("no %s row for %s", test.metric, test.url)
+			continue
+		}
+
+		row := comparison.Rows[index]
+
+		if row.Baseline != test.baseline || row.Current != test.current || row.Change != test.change ||
+			row.Delta != test.current-test.baseline || row.Regression != test.regression {
+			t.Errorf("%s %s = %+v", test.url, test.metric, row)
+		}
+	}
+
+	if len(comparison.Rows) != len(tests) {
+		t.Errorf("Compare() returned %d rows, want %d: %+v", len(comparison.Rows), len(tests), comparison.Rows)
+	}
+
+	if !comparison.HasRegression() {
+		t.Errorf("HasRegression() = false, want true")
+	}
+}
+
+func TestIsRegression(t *testing.T) {
+	tolerances := Tolerances{Latency: 10, RequestsPerSecond: 5, ErrorRate: 0.5}
+
+	tests := []struct {
+		metric            string
+		baseline, current float64
+		want              bool
+	}{
+		{MetricMean, 100, 110, false},
+		{MetricMean, 100, 111, true},
+		{MetricMean, 0, 50, false},
+		{"p99", 200, 100, false},
+		{MetricRequestsPerSecond, 100, 95, false},
+		{MetricRequestsPerSecond, 100, 94, true},
+		{MetricRequestsPerSecond, 100, 500, false},
+		{MetricRequestsPerSecond, 0, 10, false},
+		{MetricErrorRate, 0, 0.5, false},
+		{MetricErrorRate, 0, 0.6, true},
+		{MetricErrorRate, 5, 1, false},
+	}
+
+	for _, test := range tests {
+		row := newRow("http://a/", test.metric, "", test.baseline, test.current)
+
+		if got := row.isRegression(tolerances); got != test.want {
+			t.Errorf("isRegression() of %s %g -> %g = %t, want %t", test.metric, test.baseline, test.current, got, test.want)
+		}
+	}
+}
+
+func TestHasRegression(t *testing.T) {
+	if (Comparison{}).HasRegression() {
+		t.Errorf("HasRegression() of an empty comparison = true, want false")
+	}
+
+	comparison := Compare(statistics.Statistics{}, statistics.Statistics{}, Tolerances{})
+
+	if len(comparison.Rows) != 0 || comparison.HasRegression() {
+		t.Errorf("Compare() of empty statistics = %+v", comparison)
+	}
+}
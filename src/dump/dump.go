@@ -0,0 +1,159 @@
+package dump
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Writer prints the requests and the responses dumped in the verbose mode (-v, -vs),
+// in the format of curl -v: connection events start with *, the request with > and the response with <
+type Writer struct {
+	output io.Writer
+	file   *os.File
+	clear  func()
+	err    error
+}
+
+// New writes the exchanges to the file, or to the standard error if the file name is empty.
+// clear is called before every exchange written to the standard error, so it can erase the progress bar.
+func New(fileName string, clear func()) (*Writer, error) {
+	if fileName == "" {
+		return &Writer{output: os.Stderr, clear: clear}, nil
+	}
+
+	file, err := os.Create(fileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{output: file, file: file}, nil
+}
+
+// Consume writes the exchange of a dumped request. The first error stops the dump and is returned by Close.
+func (writer *Writer) Consume(result tester.MeasurementResult) {
+	exchange := result.RequestResult.Exchange
+
+	if exchange == nil || writer.err != nil {
+		return
+	}
+
+	if writer.clear != nil {
+		writer.clear()
+	}
+
+	_, writer.err = io.WriteString(writer.output, format(result, exchange))
+}
+
+func (writer *Writer) Close() error {
+	if writer.file != nil {
+		if err := writer.file.Close(); writer.err == nil {
+			writer.err = err
+		}
+	}
+
+	return writer.err
+}
+
+func format(result tester.MeasurementResult, exchange *tester.Exchange) string {
+	var dump strings.Builder
+
+	requestResult := result.RequestResult
+
+	fmt.Fprintf(
+		&dump, "=== Request #%d, worker %d, %s", exchange.Sequence, requestResult.WorkerId,
+		result.StartedAt().Format("15:04:05.000"),
+	)
+
+	if requestResult.Dispatch.Warmup {
+		dump.WriteString(", warm-up")
+	}
+
+	if requestResult.Trace.TraceId != "" {
+		fmt.Fprintf(&dump, ", trace %s", requestResult.Trace.TraceId)
+	}
+
+	dump.WriteString(" ===\n")
+
+	start := requestResult.Timing.Start
+
+	for _, event := range exchange.Events {
+		if start.IsZero() {
+			start = event.Time
+		}
+
+		fmt.Fprintf(&dump, "* [%s] %s\n", toTimeString(event.Time.Sub(start)), event.Message)
+	}
+
+	fmt.Fprintf(&dump, "> %s %s %s\n", exchange.Method, exchange.RequestUri, exchange.Proto)
+	fmt.Fprintf(&dump, "> Host: %s\n", exchange.Host)
+	writeHeaders(&dump, "> ", exchange.RequestHeaders)
+	dump.WriteString(">\n")
+	writeBody(&dump, exchange.RequestBody)
+
+	if exchange.Status != "" {
+		fmt.Fprintf(&dump, "< %s %s\n", exchange.Proto, exchange.Status)
+		writeHeaders(&dump, "< ", exchange.ResponseHeaders)
+		dump.WriteString("<\n")
+		writeBody(&dump, exchange.ResponseBody)
+	}
+
+	if err := result.PrimaryError(); err != nil {
+		fmt.Fprintf(&dump, "* Failed after %s: %v\n\n", toTimeString(requestResult.Durations.Total.Total), err)
+	} else {
+		fmt.Fprintf(&dump, "* Completed in %s\n\n", toTimeString(requestResult.Durations.Total.Total))
+	}
+
+	return dump.String()
+}
+
+func writeHeaders(dump *strings.Builder, prefix string, headers http.Header) {
+	names := make([]string, 0, len(headers))
+
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		for _, value := range headers[name] {
+			fmt.Fprintf(dump, "%s%s: %s\n", prefix, name, value)
+		}
+	}
+}
+
+// writeBody writes the excerpt of a text body as it is, a binary body is only described
+func writeBody(dump *strings.Builder, body tester.BodyExcerpt) {
+	if body.Size == 0 {
+		return
+	}
+
+	if bytes.IndexByte(body.Content, 0) >= 0 {
+		fmt.Fprintf(dump, "[%d bytes of binary data]\n", body.Size)
+		return
+	}
+
+	if len(body.Content) > 0 {
+		dump.WriteString(strings.ToValidUTF8(string(body.Content), "�"))
+
+		if !bytes.HasSuffix(body.Content, []byte("\n")) {
+			dump.WriteString("\n")
+		}
+	}
+
+	if remaining := body.Size - int64(len(body.Content)); remaining > 0 {
+		fmt.Fprintf(dump, "[%d more bytes]\n", remaining)
+	}
+}
+
+func toTimeString(duration time.Duration) string {
+	return fmt.Sprintf("%.3f ms", float64(duration)/float64(time.Millisecond))
+}
@@ -0,0 +1,325 @@
+package exporters
+
+import (
+	"errors"
+	"fmt"
+	"github.com/vpominchuk/wmetrics/src/histogram"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MeasurementInterval = "interval"
+	MeasurementSummary  = "summary"
+)
+
+// Exporter pushes points to a time-series backend
+type Exporter interface {
+	Name() string
+	Export(points []Point) error
+	Close() error
+}
+
+type Tag struct {
+	Name,
+	Value string
+}
+
+type Field struct {
+	Name    string
+	Value   float64
+	Integer bool
+}
+
+// Point is the aggregate of one URL, either of an interval of the test or of the whole test (summary)
+type Point struct {
+	Measurement string
+	Tags        []Tag
+	Fields      []Field
+	Time        time.Time
+}
+
+// Pusher aggregates the results of every URL and exports them every interval while the test runs,
+// followed by the summary of the whole test on Close. Warm-up requests are not exported.
+type Pusher struct {
+	exporters []Exporter
+	interval  time.Duration
+	tags      []Tag
+
+	// percentiles of the request time exported in every point (-p)
+	percentiles []float64
+
+	mutex     sync.Mutex
+	lastFlush time.Time
+
+	// first and last are the start and completion of the exported requests, so the summary excludes the warm-up
+	first,
+	last time.Time
+
+	intervals map[string]*aggregate
+	totals    map[string]*aggregate
+	failures  map[string]*exportFailures
+
+	stop chan bool
+	done chan bool
+}
+
+type exportFailures struct {
+	count   int
+	lastErr error
+}
+
+type aggregate struct {
+	requests,
+	failedRequests,
+	missedDispatches,
+	bytesReceived int64
+	statuses    map[string]int64
+	requestTime *histogram.Histogram
+	ttfb        *histogram.Histogram
+}
+
+var statusClasses = []string{"1xx", "2xx", "3xx", "4xx", "5xx", "other"}
+
+// NewPusher creates a pusher, the tags are added to every exported point along with the url tag
+func NewPusher(exporters []Exporter, interval time.Duration, percentiles []float64, tags []Tag) *Pusher {
+	return &Pusher{
+		exporters:   exporters,
+		interval:    interval,
+		tags:        tags,
+		percentiles: percentiles,
+		intervals:   make(map[string]*aggregate),
+		totals:      make(map[string]*aggregate),
+		failures:    make(map[string]*exportFailures),
+		stop:        make(chan bool),
+		done:        make(chan bool),
+	}
+}
+
+func (pusher *Pusher) Start() {
+	pusher.lastFlush = time.Now()
+
+	go func() {
+		defer close(pusher.done)
+
+		ticker := time.NewTicker(pusher.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				pusher.flush()
+			case <-pusher.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (pusher *Pusher) Consume(result tester.MeasurementResult) {
+	if result.RequestResult.Resource.Url == nil || result.RequestResult.Dispatch.Warmup {
+		return
+	}
+
+	url := result.RequestResult.Resource.Url.String()
+
+	pusher.mutex.Lock()
+	defer pusher.mutex.Unlock()
+
+	if pusher.first.IsZero() || result.StartedAt().Before(pusher.first) {
+		pusher.first = result.StartedAt()
+	}
+
+	if result.CompletedAt().After(pusher.last) {
+		pusher.last = result.CompletedAt()
+	}
+
+	for _, aggregates := range []map[string]*aggregate{pusher.intervals, pusher.totals} {
+		if _, ok := aggregates[url]; !ok {
+			aggregates[url] = newAggregate()
+		}
+
+		aggregates[url].add(result)
+	}
+}
+
+// Close exports the last interval and the summary, closes the exporters and returns the export failures
+func (pusher *Pusher) Close() error {
+	close(pusher.stop)
+	<-pusher.done
+
+	pusher.flush()
+
+	pusher.mutex.Lock()
+	points := pusher.points(MeasurementSummary, pusher.totals, pusher.last.Sub(pusher.first), time.Now())
+	pusher.mutex.Unlock()
+
+	pusher.export(points)
+
+	var errs []error
+
+	for _, exporter := range pusher.exporters {
+		if err := exporter.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", exporter.Name(), err))
+		}
+
+		if failures, ok := pusher.failures[exporter.Name()]; ok {
+			errs = append(
+				errs, fmt.Errorf("%s: %d exports failed, last error: %w", exporter.Name(), failures.count, failures.lastErr),
+			)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// flush exports the aggregates of the interval which ended now. Intervals without results are skipped.
+func (pusher *Pusher) flush() {
+	pusher.mutex.Lock()
+	now := time.Now()
+	points := pusher.points(MeasurementInterval, pusher.intervals, now.Sub(pusher.lastFlush), now)
+	pusher.intervals = make(map[string]*aggregate)
+	pusher.lastFlush = now
+	pusher.mutex.Unlock()
+
+	pusher.export(points)
+}
+
+func (pusher *Pusher) export(points []Point) {
+	if len(points) == 0 {
+		return
+	}
+
+	for _, exporter := range pusher.exporters {
+		if err := exporter.Export(points); err != nil {
+			pusher.mutex.Lock()
+
+			failures, ok := pusher.failures[exporter.Name()]
+
+			if !ok {
+				failures = &exportFailures{}
+				pusher.failures[exporter.Name()] = failures
+			}
+
+			failures.count++
+			failures.lastErr = err
+
+			pusher.mutex.Unlock()
+		}
+	}
+}
+
+func (pusher *Pusher) points(
+	measurement string, aggregates map[string]*aggregate, duration time.Duration, moment time.Time,
+) []Point {
+	urls := make([]string, 0, len(aggregates))
+
+	for url := range aggregates {
+		urls = append(urls, url)
+	}
+
+	slices.Sort(urls)
+
+	results := make([]Point, 0, len(urls))
+
+	for _, url := range urls {
+		results = append(
+			results, Point{
+				Measurement: measurement,
+				Tags:        append([]Tag{{Name: "url", Value: url}}, pusher.tags...),
+				Fields:      aggregates[url].fields(duration, pusher.percentiles),
+				Time:        moment,
+			},
+		)
+	}
+
+	return results
+}
+
+func newAggregate() *aggregate {
+	return &aggregate{
+		statuses:    make(map[string]int64),
+		requestTime: histogram.New(),
+		ttfb:        histogram.New(),
+	}
+}
+
+func (aggregate *aggregate) add(result tester.MeasurementResult) {
+	if result.RequestResult.Dispatch.Missed {
+		aggregate.missedDispatches++
+		return
+	}
+
+	aggregate.requests++
+	aggregate.bytesReceived += result.RequestResult.BytesReceived.Headers + result.RequestResult.BytesReceived.Body
+
+	if statusCode := result.RequestResult.StatusCode; statusCode >= 100 && statusCode < 600 {
+		aggregate.statuses[fmt.Sprintf("%dxx", statusCode/100)]++
+	} else if statusCode != 0 {
+		aggregate.statuses["other"]++
+	}
+
+	if result.PrimaryError() != nil {
+		aggregate.failedRequests++
+		return
+	}
+
+	aggregate.requestTime.Record(int64(result.RequestResult.Durations.Total.Total))
+	aggregate.ttfb.Record(int64(result.RequestResult.Durations.TTFB.Duration))
+}
+
+// fields returns the counters and the times of successful requests in milliseconds
+func (aggregate *aggregate) fields(duration time.Duration, percentiles []float64) []Field {
+	fields := []Field{
+		{Name: "requests", Value: float64(aggregate.requests), Integer: true},
+		{Name: "failed_requests", Value: float64(aggregate.failedRequests), Integer: true},
+		{Name: "missed_dispatches", Value: float64(aggregate.missedDispatches), Integer: true},
+		{Name: "bytes_received", Value: float64(aggregate.bytesReceived), Integer: true},
+		{Name: "rps", Value: float64(aggregate.requests) / max(duration.Seconds(), 0.001)},
+	}
+
+	for _, class := range statusClasses {
+		fields = append(
+			fields, Field{Name: "status_" + class, Value: float64(aggregate.statuses[class]), Integer: true},
+		)
+	}
+
+	if aggregate.requestTime.Count() == 0 {
+		return fields
+	}
+
+	fields = append(
+		fields,
+		Field{Name: "time_avg_ms", Value: aggregate.requestTime.Mean() / float64(time.Millisecond)},
+		Field{Name: "time_min_ms", Value: toMilliseconds(aggregate.requestTime.Min())},
+		Field{Name: "time_max_ms", Value: toMilliseconds(aggregate.requestTime.Max())},
+	)
+
+	for _, percentile := range percentiles {
+		fields = append(
+			fields, Field{
+				Name:  "time_" + percentileName(percentile) + "_ms",
+				Value: toMilliseconds(aggregate.requestTime.ValueAtPercentile(percentile)),
+			},
+		)
+	}
+
+	return append(
+		fields,
+		Field{Name: "ttfb_avg_ms", Value: aggregate.ttfb.Mean() / float64(time.Millisecond)},
+		Field{Name: "ttfb_p95_ms", Value: toMilliseconds(aggregate.ttfb.ValueAtPercentile(95))},
+	)
+}
+
+// percentileName returns p95 for 95 and p99_9 for 99.9, a dot would split the metric path of Graphite and StatsD
+func percentileName(percentile float64) string {
+	return "p" + strings.ReplaceAll(strconv.FormatFloat(percentile, 'f', -1, 64), ".", "_")
+}
+
+func toMilliseconds(nanoseconds int64) float64 {
+	return float64(nanoseconds) / float64(time.Millisecond)
+}
@@ -0,0 +1,114 @@
+package exporters
+
+import (
+	"errors"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"math"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("connection refused")
+
+// testPoints returns an interval point with escaped characters in the tags, an empty tag and an integer field
+func testPoints() []Point {
+	return []Point{
+		{
+			Measurement: MeasurementInterval,
+			Tags: []Tag{
+				{Name: "url", Value: "http://localhost:8080/a b?x=1,y=2"},
+				{Name: "env", Value: "ci"},
+				{Name: "region", Value: ""},
+			},
+			Fields: []Field{
+				{Name: "requests", Value: 42, Integer: true},
+				{Name: "time_p99_9_ms", Value: 12.5},
+			},
+			Time: time.Unix(1714564800, 500),
+		},
+	}
+}
+
+func TestPercentileName(t *testing.T) {
+	tests := []struct {
+		percentile float64
+		want       string
+	}{
+		{50, "p50"},
+		{95, "p95"},
+		{99.9, "p99_9"},
+		{99.99, "p99_99"},
+	}
+
+	for _, test := range tests {
+		if got := percentileName(test.percentile); got != test.want {
+			t.Errorf("percentileName(%g) = %q, want %q", test.percentile, got, test.want)
+		}
+	}
+}
+
+func TestAggregateFields(t *testing.T) {
+	requestUrl, _ := url.Parse("http://localhost/")
+	aggregate := newAggregate()
+
+	for index, statusCode := range []int{101, 200, 200, 302, 404, 503, 700} {
+		result := tester.MeasurementResult{
+			RequestResult: tester.RequestResult{
+				Resource:      tester.Resource{Url: requestUrl},
+				StatusCode:    statusCode,
+				BytesReceived: tester.BytesReceived{Headers: 100, Body: 50},
+			},
+		}
+
+		result.RequestResult.Durations.Total.Total = time.Duration(index+1) * 10 * time.Millisecond
+		result.RequestResult.Durations.TTFB.Duration = 5 * time.Millisecond
+		aggregate.add(result)
+	}
+
+	aggregate.add(
+		tester.MeasurementResult{
+			RequestResult: tester.RequestResult{Resource: tester.Resource{Url: requestUrl}, Error: errTest},
+		},
+	)
+
+	aggregate.add(
+		tester.MeasurementResult{
+			RequestResult: tester.RequestResult{Dispatch: tester.Dispatch{Missed: true}},
+		},
+	)
+
+	want := map[string]float64{
+		"requests":          8,
+		"failed_requests":   1,
+		"missed_dispatches": 1,
+		"bytes_received":    1050,
+		"rps":               4,
+		"status_1xx":        1,
+		"status_2xx":        2,
+		"status_3xx":        1,
+		"status_4xx":        1,
+		"status_5xx":        1,
+		"status_other":      1,
+		"time_avg_ms":       40,
+		"time_min_ms":       10,
+		"time_max_ms":       70,
+		"time_p50_ms":       40,
+		"time_p99_9_ms":     70,
+		"ttfb_avg_ms":       5,
+		"ttfb_p95_ms":       5,
+	}
+
+	fields := aggregate.fields(2*time.Second, []float64{50, 99.9})
+
+	if len(fields) != len(want) {
+		t.Errorf("fields() returned %d fields, want %d: %+v", len(fields), len(want), fields)
+	}
+
+	// the percentiles are read from the histogram, which keeps the values with a precision of 0.2%
+	for _, field := range fields {
+		if value, ok := want[field.Name]; !ok || math.Abs(field.Value-value) > value*0.002 {
+			t.Errorf("field %s = %g, want %g", field.Name, field.Value, value)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package exporters
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Graphite sends the fields in the plaintext protocol over TCP as tagged series
+// (wmetrics.interval.requests;url=...;method=GET value timestamp), supported since Graphite 1.1
+type Graphite struct {
+	address    string
+	timeout    time.Duration
+	connection net.Conn
+}
+
+var graphiteNameEscaper = strings.NewReplacer(";", "_", "~", "_", " ", "_", "\n", "_", "=", "_")
+
+var graphiteTagValueEscaper = strings.NewReplacer(";", "_", "~", "_", " ", "_", "\n", "_")
+
+func NewGraphite(address string, timeout time.Duration) *Graphite {
+	return &Graphite{
+		address: address,
+		timeout: timeout,
+	}
+}
+
+func (graphite *Graphite) Name() string {
+	return "graphite"
+}
+
+// Export connects on the first use and reconnects on the next export if the connection has been lost
+func (graphite *Graphite) Export(points []Point) error {
+	if graphite.connection == nil {
+		connection, err := net.DialTimeout("tcp", graphite.address, graphite.timeout)
+
+		if err != nil {
+			return err
+		}
+
+		graphite.connection = connection
+	}
+
+	_ = graphite.connection.SetWriteDeadline(time.Now().Add(graphite.timeout))
+
+	writer := bufio.NewWriter(graphite.connection)
+
+	for _, point := range points {
+		tags := ""
+
+		for _, tag := range point.Tags {
+			if tag.Value != "" {
+				tags += ";" + graphiteNameEscaper.Replace(tag.Name) + "=" + graphiteTagValueEscaper.Replace(tag.Value)
+			}
+		}
+
+		timestamp := strconv.FormatInt(point.Time.Unix(), 10)
+
+		for _, field := range point.Fields {
+			line := "wmetrics." + point.Measurement + "." + graphiteNameEscaper.Replace(field.Name) + tags + " " +
+				strconv.FormatFloat(field.Value, 'f', -1, 64) + " " + timestamp + "\n"
+
+			_, _ = writer.WriteString(line)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		_ = graphite.connection.Close()
+		graphite.connection = nil
+
+		return err
+	}
+
+	return nil
+}
+
+func (graphite *Graphite) Close() error {
+	if graphite.connection == nil {
+		return nil
+	}
+
+	return graphite.connection.Close()
+}
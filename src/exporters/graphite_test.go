@@ -0,0 +1,76 @@
+package exporters
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGraphiteExport(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+
+	defer listener.Close()
+
+	received := make(chan string, 1)
+
+	go func() {
+		connection, err := listener.Accept()
+
+		if err != nil {
+			received <- ""
+			return
+		}
+
+		data, _ := io.ReadAll(connection)
+		_ = connection.Close()
+		received <- string(data)
+	}()
+
+	graphite := NewGraphite(listener.Addr().String(), 5*time.Second)
+
+	if err := graphite.Export(testPoints()); err != nil {
+		t.Fatalf("Export() returned an error: %v", err)
+	}
+
+	if err := graphite.Close(); err != nil {
+		t.Fatalf("Close() returned an error: %v", err)
+	}
+
+	want := "wmetrics.interval.requests;url=http://localhost:8080/a_b?x=1,y=2;env=ci 42 1714564800\n" +
+		"wmetrics.interval.time_p99_9_ms;url=http://localhost:8080/a_b?x=1,y=2;env=ci 12.5 1714564800\n"
+
+	select {
+	case got := <-received:
+		if got != want {
+			t.Errorf("Export() sent %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("nothing received")
+	}
+}
+
+func TestGraphiteExportUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+
+	address := listener.Addr().String()
+	_ = listener.Close()
+
+	graphite := NewGraphite(address, time.Second)
+
+	if err := graphite.Export(testPoints()); err == nil {
+		t.Errorf("Export() to a closed port did not return an error")
+	}
+
+	if err := graphite.Close(); err != nil {
+		t.Errorf("Close() without a connection returned an error: %v", err)
+	}
+}
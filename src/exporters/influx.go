@@ -0,0 +1,90 @@
+package exporters
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Influx writes the points in the InfluxDB line protocol to the write endpoint, e.g.
+// http://localhost:8086/write?db=wmetrics (1.x) or http://localhost:8086/api/v2/write?org=org&bucket=wmetrics (2.x)
+type Influx struct {
+	url    string
+	client *http.Client
+}
+
+var influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+var influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+
+func NewInflux(url string, timeout time.Duration) *Influx {
+	return &Influx{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (influx *Influx) Name() string {
+	return "influx"
+}
+
+func (influx *Influx) Export(points []Point) error {
+	var body bytes.Buffer
+
+	for _, point := range points {
+		body.WriteString(influxLine(point))
+		body.WriteByte('\n')
+	}
+
+	response, err := influx.client.Post(influx.url, "text/plain; charset=utf-8", &body)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
+
+func (influx *Influx) Close() error {
+	influx.client.CloseIdleConnections()
+	return nil
+}
+
+// influxLine formats the point as "measurement,tag=value field=value timestamp" with the timestamp in nanoseconds
+func influxLine(point Point) string {
+	line := influxMeasurementEscaper.Replace("wmetrics_" + point.Measurement)
+
+	for _, tag := range point.Tags {
+		if tag.Value == "" {
+			continue
+		}
+
+		line += "," + influxTagEscaper.Replace(tag.Name) + "=" + influxTagEscaper.Replace(tag.Value)
+	}
+
+	fields := make([]string, 0, len(point.Fields))
+
+	for _, field := range point.Fields {
+		value := strconv.FormatFloat(field.Value, 'f', -1, 64)
+
+		if field.Integer {
+			value = strconv.FormatInt(int64(field.Value), 10) + "i"
+		}
+
+		fields = append(fields, influxTagEscaper.Replace(field.Name)+"="+value)
+	}
+
+	return line + " " + strings.Join(fields, ",") + " " + strconv.FormatInt(point.Time.UnixNano(), 10)
+}
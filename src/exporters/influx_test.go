@@ -0,0 +1,68 @@
+package exporters
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInfluxLine(t *testing.T) {
+	want := `wmetrics_interval,url=http://localhost:8080/a\ b?x\=1\,y\=2,env=ci requests=42i,time_p99_9_ms=12.5 ` +
+		"1714564800000000500"
+
+	if got := influxLine(testPoints()[0]); got != want {
+		t.Errorf("influxLine() = %q, want %q", got, want)
+	}
+}
+
+func TestInfluxExport(t *testing.T) {
+	received := make(chan string, 1)
+
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				body, _ := io.ReadAll(request.Body)
+				received <- request.URL.RawQuery + "\n" + string(body)
+				writer.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+
+	defer server.Close()
+
+	influx := NewInflux(server.URL+"/write?db=wmetrics", 5*time.Second)
+	defer influx.Close()
+
+	if err := influx.Export(testPoints()); err != nil {
+		t.Fatalf("Export() returned an error: %v", err)
+	}
+
+	want := "db=wmetrics\n" + influxLine(testPoints()[0]) + "\n"
+
+	if got := <-received; got != want {
+		t.Errorf("Export() sent %q, want %q", got, want)
+	}
+}
+
+func TestInfluxExportError(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				http.Error(writer, "database not found", http.StatusNotFound)
+			},
+		),
+	)
+
+	defer server.Close()
+
+	influx := NewInflux(server.URL+"/write?db=missing", 5*time.Second)
+	defer influx.Close()
+
+	err := influx.Export(testPoints())
+
+	if err == nil || err.Error() != "unexpected status 404 Not Found: database not found" {
+		t.Errorf("Export() = %v, want the status and the message of the response", err)
+	}
+}
@@ -0,0 +1,84 @@
+package exporters
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxStatsdPacketSize keeps the datagrams below the usual MTU
+const maxStatsdPacketSize = 1432
+
+// Statsd sends the fields as gauges over UDP. The tags are added in the DogStatsD format
+// (|#name:value), which is supported by Datadog, Telegraf and the StatsD exporter of Prometheus.
+type Statsd struct {
+	connection net.Conn
+}
+
+var statsdNameEscaper = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+
+// statsdTagValueEscaper keeps the colons of the value (e.g. of an url) as only the first colon separates the tag name
+var statsdTagValueEscaper = strings.NewReplacer("|", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+
+func NewStatsd(address string) (*Statsd, error) {
+	connection, err := net.DialTimeout("udp", address, 5*time.Second)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Statsd{connection: connection}, nil
+}
+
+func (statsd *Statsd) Name() string {
+	return "statsd"
+}
+
+func (statsd *Statsd) Export(points []Point) error {
+	var packet strings.Builder
+
+	for _, point := range points {
+		tags := make([]string, 0, len(point.Tags))
+
+		for _, tag := range point.Tags {
+			if tag.Value != "" {
+				tags = append(tags, statsdNameEscaper.Replace(tag.Name)+":"+statsdTagValueEscaper.Replace(tag.Value))
+			}
+		}
+
+		for _, field := range point.Fields {
+			line := "wmetrics." + point.Measurement + "." + statsdNameEscaper.Replace(field.Name) + ":" +
+				strconv.FormatFloat(field.Value, 'f', -1, 64) + "|g|#" + strings.Join(tags, ",")
+
+			if packet.Len() > 0 && packet.Len()+len(line)+1 > maxStatsdPacketSize {
+				if err := statsd.send(packet.String()); err != nil {
+					return err
+				}
+
+				packet.Reset()
+			}
+
+			if packet.Len() > 0 {
+				packet.WriteByte('\n')
+			}
+
+			packet.WriteString(line)
+		}
+	}
+
+	if packet.Len() > 0 {
+		return statsd.send(packet.String())
+	}
+
+	return nil
+}
+
+func (statsd *Statsd) Close() error {
+	return statsd.connection.Close()
+}
+
+func (statsd *Statsd) send(packet string) error {
+	_, err := statsd.connection.Write([]byte(packet))
+	return err
+}
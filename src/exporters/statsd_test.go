@@ -0,0 +1,98 @@
+package exporters
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsdExport(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+
+	defer listener.Close()
+
+	statsd, err := NewStatsd(listener.LocalAddr().String())
+
+	if err != nil {
+		t.Fatalf("NewStatsd() returned an error: %v", err)
+	}
+
+	defer statsd.Close()
+
+	if err := statsd.Export(testPoints()); err != nil {
+		t.Fatalf("Export() returned an error: %v", err)
+	}
+
+	want := []string{
+		"wmetrics.interval.requests:42|g|#url:http://localhost:8080/a_b?x=1_y=2,env:ci",
+		"wmetrics.interval.time_p99_9_ms:12.5|g|#url:http://localhost:8080/a_b?x=1_y=2,env:ci",
+	}
+
+	if got := readPacket(t, listener); got != strings.Join(want, "\n") {
+		t.Errorf("Export() sent %q, want %q", got, strings.Join(want, "\n"))
+	}
+}
+
+func TestStatsdPacketSize(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+
+	defer listener.Close()
+
+	statsd, err := NewStatsd(listener.LocalAddr().String())
+
+	if err != nil {
+		t.Fatalf("NewStatsd() returned an error: %v", err)
+	}
+
+	defer statsd.Close()
+
+	point := Point{Measurement: MeasurementSummary, Tags: []Tag{{Name: "url", Value: "http://localhost/"}}}
+
+	for index := 0; index < 100; index++ {
+		point.Fields = append(point.Fields, Field{Name: "field", Value: float64(index)})
+	}
+
+	if err := statsd.Export([]Point{point}); err != nil {
+		t.Fatalf("Export() returned an error: %v", err)
+	}
+
+	lines := 0
+
+	for lines < len(point.Fields) {
+		packet := readPacket(t, listener)
+
+		if len(packet) > maxStatsdPacketSize {
+			t.Errorf("packet of %d bytes is larger than %d", len(packet), maxStatsdPacketSize)
+		}
+
+		lines += strings.Count(packet, "\n") + 1
+	}
+
+	if lines != len(point.Fields) {
+		t.Errorf("Export() sent %d lines, want %d", lines, len(point.Fields))
+	}
+}
+
+func readPacket(t *testing.T, listener net.PacketConn) string {
+	t.Helper()
+
+	buffer := make([]byte, 65536)
+	_ = listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	size, _, err := listener.ReadFrom(buffer)
+
+	if err != nil {
+		t.Fatalf("unable to read a packet: %v", err)
+	}
+
+	return string(buffer[:size])
+}
@@ -0,0 +1,296 @@
+package formatter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/vpominchuk/wmetrics/src/statistics"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+)
+
+type csvColumn struct {
+	name  string
+	value func(stat statistics.SingleUrlStatistics) string
+}
+
+// PrintCsvResults writes one row per URL, followed by the statistics of all URLs. Times are in milliseconds,
+// sizes in bytes and the throughput in MB/s. The separator is a comma for CSV and a tab for TSV.
+// The header is written unless the rows are appended under existingHeader, which must match the columns,
+// e.g. the percentile columns of -p.
+func PrintCsvResults(output io.Writer, stats statistics.Statistics, separator rune, existingHeader []string) error {
+	writer := csv.NewWriter(output)
+	writer.Comma = separator
+
+	columns := csvColumns(stats)
+	timestamp := time.Now().Format(time.RFC3339)
+
+	names := []string{"time", "url"}
+
+	for _, column := range columns {
+		names = append(names, column.name)
+	}
+
+	if existingHeader == nil {
+		if err := writer.Write(names); err != nil {
+			return err
+		}
+	} else if !slices.Equal(existingHeader, names) {
+		return fmt.Errorf(
+			"the columns of the results differ from the header of the file (e.g. other -p or -rate), " +
+				"write them to a new file",
+		)
+	}
+
+	urls := stats.Urls()
+
+	if _, ok := stats[statistics.AllUrls]; ok {
+		urls = append(urls, statistics.AllUrls)
+	}
+
+	for _, url := range urls {
+		row := []string{timestamp, url}
+
+		for _, column := range columns {
+			row = append(row, column.value(stats[url]))
+		}
+
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// ReadCsvHeader returns the header of a CSV or TSV file the results are appended to
+func ReadCsvHeader(input io.Reader, separator rune) ([]string, error) {
+	reader := csv.NewReader(input)
+	reader.Comma = separator
+
+	return reader.Read()
+}
+
+func csvColumns(stats statistics.Statistics) []csvColumn {
+	columns := []csvColumn{
+		durationColumn("total_time_ms", func(stat statistics.SingleUrlStatistics) time.Duration { return stat.TotalTime }),
+		intColumn("total_requests", func(stat statistics.SingleUrlStatistics) int { return stat.TotalRequests }),
+		intColumn("success_requests", func(stat statistics.SingleUrlStatistics) int { return stat.SuccessRequests }),
+		intColumn("error_requests", func(stat statistics.SingleUrlStatistics) int { return stat.ErrorRequests }),
+		floatColumn(
+			"rps", func(stat statistics.SingleUrlStatistics) float64 {
+				return float64(stat.TotalRequests) / toSeconds(stat.TotalTime)
+			},
+		),
+		intColumn("code_1xx", func(stat statistics.SingleUrlStatistics) int { return stat.Code1xx }),
+		intColumn("code_2xx", func(stat statistics.SingleUrlStatistics) int { return stat.Code2xx }),
+		intColumn("code_3xx", func(stat statistics.SingleUrlStatistics) int { return stat.Code3xx }),
+		intColumn("code_4xx", func(stat statistics.SingleUrlStatistics) int { return stat.Code4xx }),
+		intColumn("code_5xx", func(stat statistics.SingleUrlStatistics) int { return stat.Code5xx }),
+		intColumn("code_other", func(stat statistics.SingleUrlStatistics) int { return stat.OtherCodes }),
+		intColumn("reused_connections", func(stat statistics.SingleUrlStatistics) int { return stat.ReusedConnections }),
+		intColumn("idle_connections", func(stat statistics.SingleUrlStatistics) int { return stat.IdleConnections }),
+		intColumn("missed_dispatches", func(stat statistics.SingleUrlStatistics) int { return stat.MissedDispatches }),
+		intColumn("late_dispatches", func(stat statistics.SingleUrlStatistics) int { return stat.LateDispatches }),
+		intColumn(
+			"bytes_received", func(stat statistics.SingleUrlStatistics) int { return int(stat.BytesReceived) },
+		),
+		intColumn(
+			"response_size_avg_bytes", func(stat statistics.SingleUrlStatistics) int {
+				return int(stat.ResponseSizeAvg)
+			},
+		),
+		intColumn(
+			"decoded_response_size_avg_bytes", func(stat statistics.SingleUrlStatistics) int {
+				return int(stat.DecodedResponseSizeAvg)
+			},
+		),
+		floatColumn("throughput_mbps", func(stat statistics.SingleUrlStatistics) float64 { return stat.ThroughputMBps }),
+	}
+
+	phases := []struct {
+		name                  string
+		avg, min, max, median func(stat statistics.SingleUrlStatistics) time.Duration
+	}{
+		{
+			statistics.PhaseDNSLookup,
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.DNSLookupAvg },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.DNSLookupMin },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.DNSLookupMax },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.DNSLookupMedian },
+		},
+		{
+			statistics.PhaseTCPConnection,
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.TCPConnectionAvg },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.TCPConnectionMin },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.TCPConnectionMax },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.TCPConnectionMedian },
+		},
+		{
+			statistics.PhaseTLSHandshake,
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.TLSHandshakeAvg },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.TLSHandshakeMin },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.TLSHandshakeMax },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.TLSHandshakeMedian },
+		},
+		{
+			statistics.PhaseConnectionEstablished,
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.ConnectionEstablishedAvg },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.ConnectionEstablishedMin },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.ConnectionEstablishedMax },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.ConnectionEstablishedMedian },
+		},
+		{
+			statistics.PhaseTTFB,
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.TTFBAvg },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.TTFBMin },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.TTFBMax },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.TTFBMedian },
+		},
+		{
+			statistics.PhaseContentTransfer,
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.ContentTransferAvg },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.ContentTransferMin },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.ContentTransferMax },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.ContentTransferMedian },
+		},
+		{
+			statistics.PhaseTotal,
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.RequestTimeAvg },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.RequestTimeMin },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.RequestTimeMax },
+			func(stat statistics.SingleUrlStatistics) time.Duration { return stat.RequestTimeMedian },
+		},
+	}
+
+	for _, phase := range phases {
+		columns = append(
+			columns,
+			durationColumn(phase.name+"_avg_ms", phase.avg),
+			durationColumn(phase.name+"_min_ms", phase.min),
+			durationColumn(phase.name+"_max_ms", phase.max),
+			durationColumn(phase.name+"_median_ms", phase.median),
+		)
+	}
+
+	// the percentile columns depend on -p and on the phases measured by any of the URLs
+	keys := make([]string, 0)
+
+	for _, stat := range stats {
+		for _, key := range stat.Percentiles.Keys() {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	keys = statistics.Percentiles{"": toKeySet(keys)}.Keys()
+
+	for _, phase := range statistics.Phases {
+		if !phaseMeasured(stats, phase) {
+			continue
+		}
+
+		for _, key := range keys {
+			phase, key := phase, key
+
+			columns = append(
+				columns, durationColumn(
+					phase+"_"+key+"_ms", func(stat statistics.SingleUrlStatistics) time.Duration {
+						return stat.Percentiles[phase][key]
+					},
+				),
+			)
+		}
+	}
+
+	return append(
+		columns,
+		intColumn("warmup_requests", func(stat statistics.SingleUrlStatistics) int { return stat.Warmup.TotalRequests }),
+		intColumn(
+			"warmup_error_requests", func(stat statistics.SingleUrlStatistics) int {
+				return stat.Warmup.ErrorRequests
+			},
+		),
+		intColumn(
+			"failed_assertions", func(stat statistics.SingleUrlStatistics) int {
+				failed := 0
+
+				for _, assertion := range stat.Assertions {
+					failed += assertion.Failed
+				}
+
+				return failed
+			},
+		),
+		intColumn(
+			"failed_thresholds", func(stat statistics.SingleUrlStatistics) int {
+				failed := 0
+
+				for _, threshold := range stat.Thresholds {
+					if !threshold.Passed {
+						failed++
+					}
+				}
+
+				return failed
+			},
+		),
+		csvColumn{
+			name: "interrupted",
+			value: func(stat statistics.SingleUrlStatistics) string {
+				return strconv.FormatBool(stat.Interrupted)
+			},
+		},
+	)
+}
+
+func phaseMeasured(stats statistics.Statistics, phase string) bool {
+	for _, stat := range stats {
+		if _, ok := stat.Percentiles[phase]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func toKeySet(keys []string) map[string]time.Duration {
+	set := make(map[string]time.Duration, len(keys))
+
+	for _, key := range keys {
+		set[key] = 0
+	}
+
+	return set
+}
+
+func durationColumn(name string, value func(stat statistics.SingleUrlStatistics) time.Duration) csvColumn {
+	return csvColumn{
+		name: name,
+		value: func(stat statistics.SingleUrlStatistics) string {
+			return strconv.FormatFloat(toMilliseconds(value(stat)), 'f', 3, 64)
+		},
+	}
+}
+
+func intColumn(name string, value func(stat statistics.SingleUrlStatistics) int) csvColumn {
+	return csvColumn{
+		name: name,
+		value: func(stat statistics.SingleUrlStatistics) string {
+			return strconv.Itoa(value(stat))
+		},
+	}
+}
+
+func floatColumn(name string, value func(stat statistics.SingleUrlStatistics) float64) csvColumn {
+	return csvColumn{
+		name: name,
+		value: func(stat statistics.SingleUrlStatistics) string {
+			return strconv.FormatFloat(value(stat), 'f', 3, 64)
+		},
+	}
+}
@@ -0,0 +1,181 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/vpominchuk/wmetrics/src/statistics"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func csvStatistics() statistics.Statistics {
+	return statistics.Statistics{
+		`http://localhost/search?q=a,b&name="x"`: {
+			TotalTime:       2 * time.Second,
+			TotalRequests:   10,
+			SuccessRequests: 9,
+			ErrorRequests:   1,
+			Code2xx:         9,
+			Code5xx:         1,
+			RequestTimeAvg:  1500 * time.Microsecond,
+			Percentiles: statistics.Percentiles{
+				statistics.PhaseTotal: {"p50": time.Millisecond, "p99": 3 * time.Millisecond},
+			},
+		},
+		"http://localhost/": {
+			TotalTime:     2 * time.Second,
+			TotalRequests: 4,
+			Code1xx:       4,
+			Percentiles: statistics.Percentiles{
+				statistics.PhaseTotal: {"p50": 2 * time.Millisecond, "p99": 4 * time.Millisecond},
+			},
+		},
+		statistics.AllUrls: {TotalTime: 2 * time.Second, TotalRequests: 14},
+	}
+}
+
+func TestPrintCsvResults(t *testing.T) {
+	tests := []struct {
+		name      string
+		separator rune
+	}{
+		{"csv", ','},
+		{"tsv", '\t'},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				var output bytes.Buffer
+
+				if err := PrintCsvResults(&output, csvStatistics(), test.separator, nil); err != nil {
+					t.Fatalf("PrintCsvResults() returned an error: %v", err)
+				}
+
+				if test.separator == '\t' && strings.Contains(output.String(), ",http") {
+					t.Errorf("TSV output is separated by commas: %s", output.String())
+				}
+
+				reader := csv.NewReader(&output)
+				reader.Comma = test.separator
+				records, err := reader.ReadAll()
+
+				if err != nil {
+					t.Fatalf("unable to read the output: %v", err)
+				}
+
+				if len(records) != 4 {
+					t.Fatalf("output has %d records, want the header and 3 rows", len(records))
+				}
+
+				header := records[0]
+
+				for _, column := range []string{"time", "url", "code_1xx", "code_2xx", "total_p50_ms", "total_p99_ms"} {
+					if !slices.Contains(header, column) {
+						t.Errorf("header %v has no column %s", header, column)
+					}
+				}
+
+				if header[0] != "time" || header[1] != "url" || header[len(header)-1] != "interrupted" {
+					t.Errorf("header = %v, want time, url first and interrupted last", header)
+				}
+
+				if slices.Contains(header, "ttfb_p50_ms") {
+					t.Errorf("header has percentile columns of a phase which was not measured")
+				}
+
+				// the URLs are sorted, the statistics of all URLs come last
+				wantUrls := []string{"http://localhost/", `http://localhost/search?q=a,b&name="x"`, statistics.AllUrls}
+
+				for index, record := range records[1:] {
+					if len(record) != len(header) {
+						t.Errorf("row %d has %d columns, want %d", index, len(record), len(header))
+					}
+
+					if record[1] != wantUrls[index] {
+						t.Errorf("row %d url = %q, want %q", index, record[1], wantUrls[index])
+					}
+				}
+
+				row := make(map[string]string, len(header))
+
+				for index, column := range header {
+					row[column] = records[2][index]
+				}
+
+				want := map[string]string{
+					"total_requests":    "10",
+					"error_requests":    "1",
+					"rps":               "5.000",
+					"code_1xx":          "0",
+					"code_5xx":          "1",
+					"total_avg_ms":      "1.500",
+					"total_p99_ms":      "3.000",
+					"total_time_ms":     "2000.000",
+					"interrupted":       "false",
+					"failed_thresholds": "0",
+				}
+
+				for column, value := range want {
+					if row[column] != value {
+						t.Errorf("%s = %q, want %q", column, row[column], value)
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestPrintCsvResultsQuoting(t *testing.T) {
+	var output bytes.Buffer
+
+	if err := PrintCsvResults(&output, csvStatistics(), ',', nil); err != nil {
+		t.Fatalf("PrintCsvResults() returned an error: %v", err)
+	}
+
+	if want := `,"http://localhost/search?q=a,b&name=""x""",`; !strings.Contains(output.String(), want) {
+		t.Errorf("output does not contain the quoted URL %s:\n%s", want, output.String())
+	}
+}
+
+func TestPrintCsvResultsAppend(t *testing.T) {
+	var output bytes.Buffer
+
+	if err := PrintCsvResults(&output, csvStatistics(), ';', nil); err != nil {
+		t.Fatalf("PrintCsvResults() returned an error: %v", err)
+	}
+
+	header, err := ReadCsvHeader(strings.NewReader(output.String()), ';')
+
+	if err != nil {
+		t.Fatalf("ReadCsvHeader() returned an error: %v", err)
+	}
+
+	var appended bytes.Buffer
+
+	if err := PrintCsvResults(&appended, csvStatistics(), ';', header); err != nil {
+		t.Fatalf("PrintCsvResults() under the same header returned an error: %v", err)
+	}
+
+	if strings.HasPrefix(appended.String(), "time;") || strings.Count(appended.String(), "\n") != 3 {
+		t.Errorf("appended output = %s, want 3 rows without the header", appended.String())
+	}
+
+	// other percentiles change the columns
+	stats := csvStatistics()
+	stat := stats["http://localhost/"]
+	stat.Percentiles = statistics.Percentiles{statistics.PhaseTotal: {"p90": time.Millisecond}}
+	stats["http://localhost/"] = stat
+
+	appended.Reset()
+
+	if err := PrintCsvResults(&appended, stats, ';', header); err == nil {
+		t.Errorf("PrintCsvResults() under a different header did not return an error")
+	}
+
+	if appended.Len() != 0 {
+		t.Errorf("PrintCsvResults() under a different header wrote %s", appended.String())
+	}
+}
@@ -3,20 +3,29 @@ package formatter
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/vpominchuk/wmetrics/src/compare"
 	"github.com/vpominchuk/wmetrics/src/statistics"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"io"
 	"log"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
 
-func PrintJsonResults(stat statistics.Statistics, pretty bool) {
+func PrintJsonResults(output io.Writer, stat statistics.Statistics, pretty bool) {
+	PrintJson(output, stat, pretty)
+}
+
+func PrintJson(output io.Writer, data any, pretty bool) {
 	var jsonData []byte
 	var err error
 
 	if pretty {
-		jsonData, err = json.MarshalIndent(stat, "", "  ")
+		jsonData, err = json.MarshalIndent(data, "", "  ")
 	} else {
-		jsonData, err = json.Marshal(stat)
+		jsonData, err = json.Marshal(data)
 	}
 
 	if err != nil {
@@ -24,82 +33,228 @@ func PrintJsonResults(stat statistics.Statistics, pretty bool) {
 		return
 	}
 
-	fmt.Println(string(jsonData))
+	fmt.Fprintln(output, string(jsonData))
+}
+
+func printTitle(output io.Writer, title string) {
+	fmt.Fprintln(output, title+":")
 }
 
-func printTitle(title string) {
-	fmt.Println(title + ":")
+const separator = "─────────────────────────────────────────────────────────────────────────────────────\n\n"
+
+func PrintResults(output io.Writer, stats statistics.Statistics, sortBy string) {
+	urls := stats.Urls()
+
+	for urlNum, url := range urls {
+		printTitle(output, url)
+		printSingleUrlResults(output, stats[url])
+
+		if urlNum < len(urls)-1 {
+			fmt.Fprint(output, separator)
+		}
+	}
+
+	if all, ok := stats[statistics.AllUrls]; ok {
+		fmt.Fprint(output, separator)
+		printTitle(output, "All URLs")
+		printSingleUrlResults(output, all)
+		printComparison(output, stats, sortBy)
+	}
 }
 
-func PrintResults(stats statistics.Statistics) {
-	urlNum := 0
+// PrintComparison prints the comparison of a baseline to the current statistics
+func PrintComparison(output io.Writer, comparison compare.Comparison) {
+	url := ""
+
+	for _, row := range comparison.Rows {
+		if row.Url != url {
+			if url != "" {
+				fmt.Fprintln(output)
+			}
 
-	for url, stat := range stats {
-		printTitle(url)
-		printSingleUrlResults(stat)
+			url = row.Url
 
-		if urlNum < len(stats)-1 {
-			fmt.Print("─────────────────────────────────────────────────────────────────────────────────────\n\n")
+			if url == statistics.AllUrls {
+				printTitle(output, "All URLs")
+			} else {
+				printTitle(output, url)
+			}
+
+			header := StrPadRight("(metric)", 14) +
+				StrPadRight("(baseline)", 16) +
+				StrPadRight("(current)", 16) +
+				StrPadRight("(delta)", 16) +
+				"(change)"
+
+			fmt.Fprintln(output, header)
+		}
+
+		line := StrPadRight(row.Metric, 14) +
+			StrPadRight(formatComparisonValue(row.Baseline, row.Unit), 16) +
+			StrPadRight(formatComparisonValue(row.Current, row.Unit), 16) +
+			StrPadRight(formatComparisonValue(row.Delta, row.Unit), 16) +
+			StrPadRight(formatComparisonChange(row), 10)
+
+		if row.Regression {
+			line += "REGRESSION"
 		}
 
-		urlNum++
+		fmt.Fprintln(output, line)
+	}
+
+	for _, url := range comparison.OnlyBaseline {
+		fmt.Fprintf(output, "\n* Warning: %s is missing in the current statistics\n", url)
+	}
+
+	for _, url := range comparison.OnlyCurrent {
+		fmt.Fprintf(output, "\n* Warning: %s is missing in the baseline statistics\n", url)
+	}
+}
+
+func formatComparisonChange(row compare.Row) string {
+	if row.Baseline == 0 {
+		return "-"
+	}
+
+	return fmt.Sprintf("%+.2f%%", row.Change)
+}
+
+func formatComparisonValue(value float64, unit string) string {
+	if unit == "ms" {
+		return fmt.Sprintf("%.3f ms", value)
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("%.2f %s", value, unit))
+}
+
+func printComparison(output io.Writer, stats statistics.Statistics, sortBy string) {
+	urls := stats.UrlsSortedBy(sortBy)
+	urlLength := len("(url)")
+
+	for _, url := range urls {
+		urlLength = max(urlLength, len(url))
+	}
+
+	urlLength += 2
+
+	header := "\n" + StrPadRight("Comparison, sorted by "+sortBy+":", urlLength) +
+		StrPadRight("(requests)", 12) +
+		StrPadRight("(rps)", 10) +
+		StrPadRight("(avg)", 15) +
+		StrPadRight("(median)", 15) +
+		StrPadRight("(p95)", 15) +
+		"(errors)"
+
+	fmt.Fprintln(output, header)
+
+	for _, url := range urls {
+		stat := stats[url]
+
+		line := StrPadRight(url, urlLength) +
+			StrPadRight(strconv.Itoa(stat.TotalRequests), 12) +
+			StrPadRight(fmt.Sprintf("%.2f", float64(stat.TotalRequests)/toSeconds(stat.TotalTime)), 10) +
+			StrPadRight(toTimeString(stat.RequestTimeAvg), 15) +
+			StrPadRight(toTimeString(stat.RequestTimeMedian), 15) +
+			StrPadRight(toTimeString(stat.Percentiles[statistics.PhaseTotal]["p95"]), 15) +
+			strconv.Itoa(stat.ErrorRequests)
+
+		fmt.Fprintln(output, line)
 	}
 }
 
-func printSingleUrlResults(stat statistics.SingleUrlStatistics) {
+func printSingleUrlResults(output io.Writer, stat statistics.SingleUrlStatistics) {
 	strLength := 30
 
+	if stat.Interrupted {
+		fmt.Fprintln(output, "Test was interrupted, the results are partial")
+	}
+
 	if stat.Server != "" {
-		fmt.Printf(StrPadRight("Server:", strLength)+"%s\n", stat.Server)
+		fmt.Fprintf(output, StrPadRight("Server:", strLength)+"%s\n", stat.Server)
 	}
 
 	if stat.PoweredBy != "" {
-		fmt.Printf(StrPadRight("Powered By:", strLength)+"%s\n", stat.PoweredBy)
+		fmt.Fprintf(output, StrPadRight("Powered By:", strLength)+"%s\n", stat.PoweredBy)
 	}
 
-	fmt.Printf(StrPadRight("Complete requests:", strLength)+"%d\n", stat.TotalRequests)
-	fmt.Printf(StrPadRight("Successful requests:", strLength)+"%d\n", stat.SuccessRequests)
-	fmt.Printf(StrPadRight("Failed requests:", strLength)+"%d\n", stat.ErrorRequests)
+	fmt.Fprintf(output, StrPadRight("Complete requests:", strLength)+"%d\n", stat.TotalRequests)
+	fmt.Fprintf(output, StrPadRight("Successful requests:", strLength)+"%d\n", stat.SuccessRequests)
+	fmt.Fprintf(output, StrPadRight("Failed requests:", strLength)+"%d\n", stat.ErrorRequests)
+	fmt.Fprintf(
+		output,
+		StrPadRight("Reused connections:", strLength)+"%d (idle: %d)\n", stat.ReusedConnections, stat.IdleConnections,
+	)
+
+	if stat.Warmup.TotalRequests > 0 {
+		fmt.Fprintf(
+			output,
+			StrPadRight("Warm-up requests:", strLength)+"%d (failed: %d, avg: %s, max: %s), not included below\n",
+			stat.Warmup.TotalRequests,
+			stat.Warmup.ErrorRequests,
+			toTimeString(stat.Warmup.RequestTimeAvg),
+			toTimeString(stat.Warmup.RequestTimeMax),
+		)
+	}
 
-	fmt.Println("\nPerformance Metrics:")
-	fmt.Printf(StrPadRight("Total time taken for tests:", strLength)+"%s\n", toTimeString(stat.TotalTime))
-	fmt.Printf(StrPadRight("Time per request (avg):", strLength)+"%s\n", toTimeString(stat.RequestTimeAvg))
-	fmt.Printf(StrPadRight("Time per request (median):", strLength)+"%s\n", toTimeString(stat.RequestTimeMedian))
-	fmt.Printf(StrPadRight("Time per request (min):", strLength)+"%s\n", toTimeString(stat.RequestTimeMin))
-	fmt.Printf(StrPadRight("Time per request (max):", strLength)+"%s\n", toTimeString(stat.RequestTimeMax))
-	fmt.Printf(
+	if stat.MissedDispatches > 0 || stat.LateDispatches > 0 {
+		fmt.Fprintf(output, StrPadRight("Missed dispatches:", strLength)+"%d\n", stat.MissedDispatches)
+		fmt.Fprintf(output, StrPadRight("Late dispatches:", strLength)+"%d\n", stat.LateDispatches)
+	}
+
+	fmt.Fprintln(output, "\nPerformance Metrics:")
+	fmt.Fprintf(output, StrPadRight("Total time taken for tests:", strLength)+"%s\n", toTimeString(stat.TotalTime))
+	fmt.Fprintf(output, StrPadRight("Time per request (avg):", strLength)+"%s\n", toTimeString(stat.RequestTimeAvg))
+	fmt.Fprintf(output, StrPadRight("Time per request (median):", strLength)+"%s\n", toTimeString(stat.RequestTimeMedian))
+	fmt.Fprintf(output, StrPadRight("Time per request (min):", strLength)+"%s\n", toTimeString(stat.RequestTimeMin))
+	fmt.Fprintf(output, StrPadRight("Time per request (max):", strLength)+"%s\n", toTimeString(stat.RequestTimeMax))
+	fmt.Fprintf(
+		output,
 		StrPadRight("Requests per second:", strLength)+"%.2f\n", float64(stat.TotalRequests)/toSeconds(stat.TotalTime),
 	)
 
+	fmt.Fprintf(output, StrPadRight("Total received:", strLength)+"%d bytes\n", stat.BytesReceived)
+	fmt.Fprintf(output, StrPadRight("Response size (avg):", strLength)+"%d bytes", stat.ResponseSizeAvg)
+
+	if stat.DecodedResponseSizeAvg != stat.ResponseSizeAvg {
+		fmt.Fprintf(output, " (%d bytes decoded)", stat.DecodedResponseSizeAvg)
+	}
+
+	fmt.Fprintln(output)
+	fmt.Fprintf(output, StrPadRight("Throughput:", strLength)+"%.3f MB/s\n", stat.ThroughputMBps)
+
+	if stat.Code1xx > 0 {
+		fmt.Fprintf(output, StrPadRight("1xx responses:", strLength)+"%d\n", stat.Code1xx)
+	}
+
 	if stat.Code2xx > 0 {
-		fmt.Printf(StrPadRight("2xx responses:", strLength)+"%d\n", stat.Code2xx)
+		fmt.Fprintf(output, StrPadRight("2xx responses:", strLength)+"%d\n", stat.Code2xx)
 	}
 
 	if stat.Code3xx > 0 {
-		fmt.Printf(StrPadRight("3xx responses:", strLength)+"%d\n", stat.Code3xx)
+		fmt.Fprintf(output, StrPadRight("3xx responses:", strLength)+"%d\n", stat.Code3xx)
 	}
 
 	if stat.Code4xx > 0 {
-		fmt.Printf(StrPadRight("4xx responses:", strLength)+"%d\n", stat.Code4xx)
+		fmt.Fprintf(output, StrPadRight("4xx responses:", strLength)+"%d\n", stat.Code4xx)
 	}
 
 	if stat.Code5xx > 0 {
-		fmt.Printf(StrPadRight("5xx responses:", strLength)+"%d\n", stat.Code5xx)
+		fmt.Fprintf(output, StrPadRight("5xx responses:", strLength)+"%d\n", stat.Code5xx)
 	}
 
 	if stat.OtherCodes > 0 {
-		fmt.Printf(StrPadRight("Other responses:", strLength)+"%d\n", stat.OtherCodes)
+		fmt.Fprintf(output, StrPadRight("Other responses:", strLength)+"%d\n", stat.OtherCodes)
 	}
 
-	fmt.Println(
-		StrPadRight("\nConnection Metrics:", strLength+3) +
-			StrPadRight("(avg)", 13) +
-			StrPadRight("(median)", 17) +
-			StrPadRight("(min)", 15) +
-			StrPadRight("(max)", 15),
-	)
+	header := StrPadRight("\nConnection Metrics:", strLength+3) +
+		StrPadRight("(avg)", 13) +
+		StrPadRight("(median)", 17) +
+		StrPadRight("(min)", 15) +
+		StrPadRight("(max)", 15)
 
-	printDurations(
+	fmt.Fprintln(output, header)
+
+	printDurations(output,
 		"DNS lookup:",
 		toTimeString(stat.DNSLookupAvg),
 		toTimeString(stat.DNSLookupMedian),
@@ -108,7 +263,7 @@ func printSingleUrlResults(stat statistics.SingleUrlStatistics) {
 		strLength,
 	)
 
-	printDurations(
+	printDurations(output,
 		"TCP connection:",
 		toTimeString(stat.TCPConnectionAvg),
 		toTimeString(stat.TCPConnectionMedian),
@@ -117,7 +272,7 @@ func printSingleUrlResults(stat statistics.SingleUrlStatistics) {
 		strLength,
 	)
 
-	printDurations(
+	printDurations(output,
 		"TLS handshake:",
 		toTimeString(stat.TLSHandshakeAvg),
 		toTimeString(stat.TLSHandshakeMedian),
@@ -126,7 +281,7 @@ func printSingleUrlResults(stat statistics.SingleUrlStatistics) {
 		strLength,
 	)
 
-	printDurations(
+	printDurations(output,
 		"Connection established:",
 		toTimeString(stat.ConnectionEstablishedAvg),
 		toTimeString(stat.ConnectionEstablishedMedian),
@@ -135,7 +290,7 @@ func printSingleUrlResults(stat statistics.SingleUrlStatistics) {
 		strLength,
 	)
 
-	printDurations(
+	printDurations(output,
 		"TTFB:",
 		toTimeString(stat.TTFBAvg),
 		toTimeString(stat.TTFBMedian),
@@ -144,25 +299,385 @@ func printSingleUrlResults(stat statistics.SingleUrlStatistics) {
 		strLength,
 	)
 
-	if stat.TotalTimePercentage != nil && len(stat.TotalTimePercentage) > 0 {
-		fmt.Println("\nPercentage of the requests served within a certain time (ms):")
+	printDurations(output,
+		"Content transfer:",
+		toTimeString(stat.ContentTransferAvg),
+		toTimeString(stat.ContentTransferMedian),
+		toTimeString(stat.ContentTransferMin),
+		toTimeString(stat.ContentTransferMax),
+		strLength,
+	)
+
+	if len(stat.Percentiles) > 0 {
+		printPercentiles(output, stat, strLength)
+	}
 
-		for _, result := range stat.TotalTimePercentage {
-			fmt.Print(StrPadRight(fmt.Sprintf("%d%%", result.Segment*10), 7))
-			fmt.Print(StrPadRight(fmt.Sprintf("%.3f ms", toMilliseconds((result.Min+result.Max)/2)), 15))
-			fmt.Printf("(%.3f - %.3f ms)\n", toMilliseconds(result.Min), toMilliseconds(result.Max))
-		}
+	if len(stat.Stages) > 0 {
+		printStages(output, stat.Stages)
+	}
+
+	if len(stat.Timeline) > 0 {
+		printTimeline(output, stat.Timeline, strLength)
+	}
+
+	if len(stat.Assertions) > 0 {
+		printAssertions(output, stat.Assertions)
+	}
+
+	if len(stat.Thresholds) > 0 {
+		printThresholds(output, stat.Thresholds)
+	}
+
+	if len(stat.SlowestRequests) > 0 {
+		printRequestSamples(output, "Slowest requests:", stat.SlowestRequests, strLength)
+	}
+
+	if len(stat.FastestRequests) > 0 {
+		printRequestSamples(output, "Fastest requests:", stat.FastestRequests, strLength)
 	}
 
 	if stat.Errors != nil && len(stat.Errors) > 0 {
-		fmt.Println("\nErrors:")
+		fmt.Fprintln(output, "\nErrors:")
 
 		for _, result := range stat.Errors {
-			fmt.Printf("%s (%d times)\n", result.Message, result.Count)
+			fmt.Fprintf(
+				output,
+				StrPadRight(errorClassTitle(result.Class), strLength)+"%d (e.g. %s)\n", result.Count, result.Message,
+			)
 		}
 	}
 }
 
+func printStages(output io.Writer, stages []statistics.StageStatistics) {
+	header := "\n" + StrPadRight("Load stages:", 24) +
+		StrPadRight("(requests)", 12) +
+		StrPadRight("(rps)", 10) +
+		StrPadRight("(avg)", 15) +
+		StrPadRight("(median)", 15) +
+		StrPadRight("(p95)", 15) +
+		"(errors)"
+
+	fmt.Fprintln(output, header)
+
+	for _, stage := range stages {
+		var description string
+
+		if stage.From == stage.To {
+			description = fmt.Sprintf("%d. hold %d %s", stage.Stage, stage.To, stage.Duration)
+		} else {
+			description = fmt.Sprintf("%d. %d->%d %s", stage.Stage, stage.From, stage.To, stage.Duration)
+		}
+
+		line := StrPadRight(description, 24) +
+			StrPadRight(strconv.Itoa(stage.TotalRequests), 12) +
+			StrPadRight(fmt.Sprintf("%.2f", float64(stage.TotalRequests)/toSeconds(stage.Duration)), 10) +
+			StrPadRight(toTimeString(stage.RequestTimeAvg), 15) +
+			StrPadRight(toTimeString(stage.RequestTimeMedian), 15) +
+			StrPadRight(toTimeString(stage.Percentiles[statistics.PhaseTotal]["p95"]), 15) +
+			strconv.Itoa(stage.ErrorRequests)
+
+		fmt.Fprintln(output, line)
+	}
+}
+
+const maxTimelinePoints = 60
+
+var sparklineLevels = []rune("▁▂▃▄▅▆▇█")
+
+func printTimeline(output io.Writer, timeline []statistics.TimelineBucket, strLength int) {
+	points := mergeTimelineBuckets(timeline)
+
+	fmt.Fprintf(
+		output, "\nTimeline (%s per point, %d points):\n", points[0].Duration.Round(time.Millisecond), len(points),
+	)
+
+	requestsPerSecond := make([]float64, 0, len(points))
+	median := make([]float64, 0, len(points))
+	p95 := make([]float64, 0, len(points))
+	errors := make([]float64, 0, len(points))
+	totalErrors := 0
+
+	for _, point := range points {
+		requestsPerSecond = append(requestsPerSecond, point.RequestsPerSecond)
+		median = append(median, toMilliseconds(point.RequestTimeMedian))
+		p95 = append(p95, toMilliseconds(point.Percentiles["p95"]))
+		errors = append(errors, float64(point.ErrorRequests))
+		totalErrors += point.ErrorRequests
+	}
+
+	printSparkline(output, "Requests per second:", requestsPerSecond, "%.2f", strLength)
+	printSparkline(output, "Time per request (median):", median, "%.3f ms", strLength)
+
+	if _, ok := points[0].Percentiles["p95"]; ok {
+		printSparkline(output, "Time per request (p95):", p95, "%.3f ms", strLength)
+	}
+
+	if totalErrors > 0 {
+		printSparkline(output, "Failed requests:", errors, "%.0f", strLength)
+	}
+}
+
+// mergeTimelineBuckets joins adjacent buckets of a long timeline, so it fits into maxTimelinePoints.
+// Latencies of the merged buckets are the worst of the joined ones.
+func mergeTimelineBuckets(timeline []statistics.TimelineBucket) []statistics.TimelineBucket {
+	size := (len(timeline) + maxTimelinePoints - 1) / maxTimelinePoints
+
+	if size <= 1 {
+		return timeline
+	}
+
+	points := make([]statistics.TimelineBucket, 0, maxTimelinePoints)
+
+	for from := 0; from < len(timeline); from += size {
+		point := statistics.TimelineBucket{
+			Offset:      timeline[from].Offset,
+			Percentiles: make(map[string]time.Duration),
+		}
+
+		for _, bucket := range timeline[from:min(from+size, len(timeline))] {
+			point.Duration += bucket.Duration
+			point.TotalRequests += bucket.TotalRequests
+			point.ErrorRequests += bucket.ErrorRequests
+			point.RequestTimeMedian = max(point.RequestTimeMedian, bucket.RequestTimeMedian)
+
+			for key, value := range bucket.Percentiles {
+				point.Percentiles[key] = max(point.Percentiles[key], value)
+			}
+		}
+
+		point.RequestsPerSecond = float64(point.TotalRequests) / toSeconds(point.Duration)
+		points = append(points, point)
+	}
+
+	return points
+}
+
+func printSparkline(output io.Writer, title string, values []float64, format string, strLength int) {
+	maxValue := slices.Max(values)
+	line := make([]rune, 0, len(values))
+
+	for _, value := range values {
+		level := 0
+
+		if maxValue > 0 {
+			level = int(value / maxValue * float64(len(sparklineLevels)-1))
+		}
+
+		line = append(line, sparklineLevels[level])
+	}
+
+	row := StrPadRight(title, strLength) + string(line) +
+		fmt.Sprintf("  min "+format+", max "+format, slices.Min(values), maxValue)
+
+	fmt.Fprintln(output, row)
+}
+
+func printAssertions(output io.Writer, assertions []statistics.AssertionResult) {
+	assertionLength := len("Assertions:")
+
+	for _, assertion := range assertions {
+		assertionLength = max(assertionLength, len(assertion.Assertion))
+	}
+
+	assertionLength += 2
+
+	fmt.Fprintln(output, "\n"+StrPadRight("Assertions:", assertionLength)+StrPadRight("(passed)", 12)+"(failed)")
+
+	for _, assertion := range assertions {
+		line := StrPadRight(assertion.Assertion, assertionLength) +
+			StrPadRight(strconv.Itoa(assertion.Passed), 12) +
+			strconv.Itoa(assertion.Failed)
+
+		fmt.Fprintln(output, line)
+	}
+}
+
+func printThresholds(output io.Writer, thresholds []statistics.ThresholdResult) {
+	thresholdLength := len("Thresholds:")
+
+	for _, threshold := range thresholds {
+		thresholdLength = max(thresholdLength, len(threshold.Threshold))
+	}
+
+	thresholdLength += 2
+
+	fmt.Fprintln(output, "\n"+StrPadRight("Thresholds:", thresholdLength)+StrPadRight("(actual)", 20)+"(result)")
+
+	for _, threshold := range thresholds {
+		result := "FAIL"
+
+		if threshold.Passed {
+			result = "pass"
+		}
+
+		actual := strings.TrimSpace(fmt.Sprintf("%.3f %s", threshold.Actual, threshold.Unit))
+
+		if threshold.NoSamples {
+			actual = "n/a"
+		}
+
+		line := StrPadRight(threshold.Threshold, thresholdLength) + StrPadRight(actual, 20) + result
+
+		fmt.Fprintln(output, line)
+	}
+}
+
+// printRequestSamples prints the connection phases of every request as a waterfall,
+// the start of a phase is relative to the start of the request
+func printRequestSamples(output io.Writer, title string, samples []statistics.RequestSample, strLength int) {
+	header := "\n" + StrPadRight(title, strLength) +
+		StrPadRight("(start)", 15) +
+		StrPadRight("(duration)", 15)
+
+	fmt.Fprint(output, header)
+
+	for index, sample := range samples {
+		details := []string{sample.StartedAt.Format("15:04:05.000"), requestStatus(sample.StatusCode, sample.ErrorClass)}
+
+		if sample.RemoteAddr != "" {
+			details = append(details, sample.RemoteAddr)
+		}
+
+		if sample.Reused {
+			details = append(details, "reused connection")
+		} else {
+			details = append(details, "new connection")
+		}
+
+		if sample.TraceId != "" {
+			details = append(details, "trace id "+sample.TraceId, "span id "+sample.SpanId)
+		}
+
+		fmt.Fprintf(output, "\n%d. %s\n", index+1, strings.Join(details, ", "))
+
+		durations := sample.Durations
+		total := durations.Total.Total
+
+		for _, phase := range []struct {
+			title    string
+			duration tester.Duration
+		}{
+			{"DNS lookup:", durations.DNSLookup},
+			{"TCP connection:", durations.TCPConnection},
+			{"TLS handshake:", durations.TLSHandshake},
+			{"Connection established:", durations.ConnectionEstablishment},
+			{"TTFB:", durations.TTFB},
+			{"Content transfer:", durations.ContentTransfer},
+		} {
+			start := phase.duration.Total - phase.duration.Duration
+
+			// the phases after the failure of a request did not happen
+			if start < 0 || phase.duration.Duration < 0 {
+				fmt.Fprintln(output, StrPadRight("   "+phase.title, strLength)+StrPadRight("-", 15)+"-")
+				continue
+			}
+
+			line := StrPadRight("   "+phase.title, strLength) +
+				StrPadRight(toTimeString(start), 15) +
+				StrPadRight(toTimeString(phase.duration.Duration), 15) +
+				waterfallBar(start, phase.duration.Duration, total)
+
+			fmt.Fprintln(output, line)
+		}
+
+		line := StrPadRight("   Time per request:", strLength) +
+			StrPadRight(toTimeString(0), 15) +
+			toTimeString(total)
+
+		fmt.Fprintln(output, line)
+	}
+}
+
+const waterfallWidth = 30
+
+// waterfallBar draws the phase in a lane of waterfallWidth characters which spans the whole request
+func waterfallBar(start time.Duration, duration time.Duration, total time.Duration) string {
+	if total <= 0 || duration <= 0 {
+		return ""
+	}
+
+	offset := min(int(float64(start)/float64(total)*waterfallWidth), waterfallWidth-1)
+	length := max(int(float64(duration)/float64(total)*waterfallWidth), 1)
+
+	return strings.Repeat(" ", offset) + strings.Repeat("█", min(length, waterfallWidth-offset))
+}
+
+// requestStatus returns the status code of the request or the error class if it failed
+func requestStatus(statusCode int, errorClass string) string {
+	if errorClass != "" {
+		return errorClass
+	}
+
+	return strconv.Itoa(statusCode)
+}
+
+var errorClassTitles = map[string]string{
+	tester.ErrorClassDNS:                   "DNS failure:",
+	tester.ErrorClassConnectionRefused:     "Connection refused:",
+	tester.ErrorClassConnectTimeout:        "Connect timeout:",
+	tester.ErrorClassTLS:                   "TLS failure:",
+	tester.ErrorClassResponseHeaderTimeout: "Response header timeout:",
+	tester.ErrorClassTimeout:               "Timeout:",
+	tester.ErrorClassConnectionReset:       "Reset by peer:",
+	tester.ErrorClassProxy:                 "Proxy error:",
+	tester.ErrorClassHttpCode:              "HTTP code error:",
+	tester.ErrorClassAssertion:             "Assertion failed:",
+	tester.ErrorClassCanceled:              "Canceled:",
+	tester.ErrorClassOther:                 "Other errors:",
+}
+
+func errorClassTitle(class string) string {
+	if title, ok := errorClassTitles[class]; ok {
+		return title
+	}
+
+	return class + ":"
+}
+
+var phaseTitles = map[string]string{
+	statistics.PhaseDNSLookup:             "DNS lookup:",
+	statistics.PhaseTCPConnection:         "TCP connection:",
+	statistics.PhaseTLSHandshake:          "TLS handshake:",
+	statistics.PhaseConnectionEstablished: "Connection established:",
+	statistics.PhaseTTFB:                  "TTFB:",
+	statistics.PhaseContentTransfer:       "Content transfer:",
+	statistics.PhaseTotal:                 "Time per request:",
+	statistics.PhaseCorrectedTotal:        "Time per request (corrected):",
+}
+
+func printPercentiles(output io.Writer, stat statistics.SingleUrlStatistics, strLength int) {
+	keys := stat.Percentiles.Keys()
+
+	header := StrPadRight("\nPercentiles:", strLength+1)
+
+	for _, key := range keys {
+		header += StrPadRight(key, 15)
+	}
+
+	fmt.Fprintln(output, header)
+
+	for _, phase := range statistics.Phases {
+		values, ok := stat.Percentiles[phase]
+
+		if !ok {
+			continue
+		}
+
+		line := StrPadRight(phaseTitles[phase], strLength)
+
+		for _, key := range keys {
+			line += StrPadRight(toTimeString(values[key]), 15)
+		}
+
+		fmt.Fprintln(output, line)
+	}
+
+	if stat.CoordinatedOmissionCorrection {
+		fmt.Fprintln(output, "(corrected) - measured from the scheduled start of each request (coordinated omission), "+
+			"missed dispatches count until the end of the test")
+	}
+}
+
 func toMilliseconds(duration time.Duration) float64 {
 	return float64(duration) / float64(time.Millisecond)
 }
@@ -185,12 +700,12 @@ func StrPadRight(string string, count int) string {
 	return string + strings.Repeat(" ", padLength)
 }
 
-func printDurations(title string, avg, median, min, max string, strLength int) {
-	fmt.Println(
-		StrPadRight(title, strLength) +
-			StrPadRight(avg, 15) +
-			StrPadRight(median, 15) +
-			StrPadRight(min, 15) +
-			StrPadRight(max, 15),
-	)
+func printDurations(output io.Writer, title string, avg, median, min, max string, strLength int) {
+	line := StrPadRight(title, strLength) +
+		StrPadRight(avg, 15) +
+		StrPadRight(median, 15) +
+		StrPadRight(min, 15) +
+		StrPadRight(max, 15)
+
+	fmt.Fprintln(output, line)
 }
@@ -0,0 +1,658 @@
+package formatter
+
+import (
+	_ "embed"
+	"fmt"
+	"github.com/vpominchuk/wmetrics/src/app"
+	"github.com/vpominchuk/wmetrics/src/histogram"
+	"github.com/vpominchuk/wmetrics/src/statistics"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"html/template"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed report.html
+var htmlReportTemplate string
+
+// histogramBars is the number of bars of the latency histogram chart
+const histogramBars = 40
+
+// percentileCurvePoints are the percentiles plotted on the percentile curve
+var percentileCurvePoints = []float64{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 95, 99, 99.9, 99.99, 100}
+
+type htmlReport struct {
+	Version     string
+	Generated   string
+	Interrupted bool
+	Parameters  []htmlParameter
+	Percentiles []string
+	Summary     []htmlSummaryRow
+	Urls        []htmlUrlReport
+}
+
+type htmlParameter struct {
+	Name,
+	Value string
+}
+
+type htmlSummaryRow struct {
+	Url               string
+	TotalRequests     int
+	ErrorRequests     int
+	ErrorRate         string
+	RequestsPerSecond string
+	Avg, Median, Max  string
+	Percentiles       []string
+	Codes             string
+	Throughput        string
+}
+
+type htmlUrlReport struct {
+	Title           string
+	Histogram       *htmlChart
+	PercentileCurve *htmlChart
+	Throughput      *htmlChart
+	Latency         *htmlChart
+	Waterfall       *htmlChart
+	Errors          []htmlError
+	SlowestRequests []htmlRequest
+}
+
+type htmlRequest struct {
+	StartedAt,
+	Status,
+	RemoteAddr,
+	Connection,
+	TraceId,
+	SpanId,
+	RequestTime string
+}
+
+type htmlError struct {
+	Title   string
+	Message string
+	Count   int
+	Share   string
+}
+
+// htmlChart holds the geometry of an inline SVG chart, so the template only draws the prepared elements
+type htmlChart struct {
+	Width,
+	Height float64
+	Grid   []htmlChartLabel
+	Labels []htmlChartLabel
+	Bars   []htmlChartBar
+	Lines  []htmlChartLine
+	Legend []htmlChartLine
+}
+
+type htmlChartLabel struct {
+	X, Y   float64
+	Anchor string
+	Text   string
+}
+
+type htmlChartBar struct {
+	X, Y, Width, Height float64
+	Class               string
+	Title               string
+}
+
+type htmlChartLine struct {
+	Points string
+	Class  string
+	Title  string
+}
+
+const (
+	chartWidth        = 860
+	chartHeight       = 240
+	chartMarginLeft   = 70
+	chartMarginRight  = 20
+	chartMarginTop    = 15
+	chartMarginBottom = 40
+)
+
+// PrintHtmlResults writes a self-contained HTML report with inline SVG charts, which can be shared without wmetrics
+func PrintHtmlResults(
+	output io.Writer,
+	parameters tester.Parameters,
+	stats statistics.Statistics,
+	sortBy string,
+) error {
+	reportTemplate, err := template.New("report").Funcs(
+		template.FuncMap{
+			"coordinate": func(value float64) string { return strconv.FormatFloat(value, 'f', 1, 64) },
+		},
+	).Parse(htmlReportTemplate)
+
+	if err != nil {
+		return err
+	}
+
+	urls := stats.UrlsSortedBy(sortBy)
+
+	if _, ok := stats[statistics.AllUrls]; ok {
+		urls = append(urls, statistics.AllUrls)
+	}
+
+	report := htmlReport{
+		Version:    strings.TrimSpace(app.ExecutableName + " " + app.VersionString),
+		Generated:  time.Now().Format(time.RFC1123),
+		Parameters: htmlParameters(parameters),
+	}
+
+	keys := make([]string, 0)
+
+	for _, url := range urls {
+		stat := stats[url]
+		report.Interrupted = report.Interrupted || stat.Interrupted
+
+		if len(stat.Percentiles.Keys()) > len(keys) {
+			keys = stat.Percentiles.Keys()
+		}
+	}
+
+	report.Percentiles = keys
+
+	for _, url := range urls {
+		stat := stats[url]
+		title := url
+
+		if url == statistics.AllUrls {
+			title = "All URLs"
+		}
+
+		report.Summary = append(report.Summary, htmlSummary(title, stat, keys))
+
+		report.Urls = append(
+			report.Urls, htmlUrlReport{
+				Title:           title,
+				Histogram:       histogramChart(stat.Histograms[statistics.PhaseTotal]),
+				PercentileCurve: percentileCurveChart(stat.Histograms[statistics.PhaseTotal]),
+				Throughput:      throughputChart(stat.Timeline),
+				Latency:         latencyChart(stat.Timeline),
+				Waterfall:       waterfallChart(stat),
+				Errors:          htmlErrors(stat),
+				SlowestRequests: htmlRequests(stat.SlowestRequests),
+			},
+		)
+	}
+
+	return reportTemplate.Execute(output, report)
+}
+
+func htmlParameters(parameters tester.Parameters) []htmlParameter {
+	results := make([]htmlParameter, 0)
+
+	add := func(name string, value string) {
+		if value != "" && value != "0" && value != "0s" && value != "false" {
+			results = append(results, htmlParameter{Name: name, Value: value})
+		}
+	}
+
+	urls := make([]string, 0, len(parameters.Resources))
+
+	for _, resource := range parameters.Resources {
+		urls = append(urls, resource.Url.String())
+	}
+
+	stages := make([]string, 0, len(parameters.Stages))
+
+	for _, stage := range parameters.Stages {
+		stages = append(stages, fmt.Sprintf("%s:%d", stage.Duration, stage.Target))
+	}
+
+	assertions := make([]string, 0, len(parameters.Assertions))
+
+	for _, assertion := range parameters.Assertions {
+		assertions = append(assertions, assertion.Name)
+	}
+
+	percentiles := make([]string, 0, len(parameters.Percentiles))
+
+	for _, percentile := range parameters.Percentiles {
+		percentiles = append(percentiles, strconv.FormatFloat(percentile, 'f', -1, 64))
+	}
+
+	add("URLs", strings.Join(urls, "\n"))
+	add("Method", parameters.Method)
+
+	if parameters.TimeLimit == 0 && len(parameters.Stages) == 0 {
+		add("Requests", strconv.Itoa(parameters.Requests))
+	}
+
+	add("Concurrency", strconv.Itoa(parameters.Concurrency))
+
+	if parameters.Rate > 0 {
+		add("Rate", fmt.Sprintf("%.2f/s", parameters.Rate))
+	}
+
+	add("Load stages", strings.Join(stages, ", "))
+	add("Time limit", parameters.TimeLimit.String())
+	add("Timeout", parameters.Timeout.String())
+	add("Warm-up requests", strconv.Itoa(parameters.WarmupRequests))
+	add("Warm-up period", parameters.WarmupDuration.String())
+	add("KeepAlive", strconv.FormatBool(parameters.KeepAlive))
+
+	if parameters.KeepAlive {
+		add("Connection pool", parameters.ConnectionPool)
+	}
+
+	add("Proxy", parameters.Proxy)
+	add("User Agent", parameters.UserAgent)
+	add("Headers", strings.Join(parameters.CustomHeaders, "\n"))
+	add("Content type", parameters.ContentType)
+	add("Post data file", parameters.PostDataFile)
+	add("Allow insecure SSL", strconv.FormatBool(parameters.AllowInsecureSSL))
+	add("Percentiles", strings.Join(percentiles, ", "))
+	add("Assertions", strings.Join(assertions, "\n"))
+	add("Thresholds", strings.Join(parameters.Thresholds, "\n"))
+
+	return results
+}
+
+func htmlSummary(title string, stat statistics.SingleUrlStatistics, keys []string) htmlSummaryRow {
+	row := htmlSummaryRow{
+		Url:               title,
+		TotalRequests:     stat.TotalRequests,
+		ErrorRequests:     stat.ErrorRequests,
+		RequestsPerSecond: fmt.Sprintf("%.2f", float64(stat.TotalRequests)/toSeconds(stat.TotalTime)),
+		Avg:               toTimeString(stat.RequestTimeAvg),
+		Median:            toTimeString(stat.RequestTimeMedian),
+		Max:               toTimeString(stat.RequestTimeMax),
+		Throughput:        fmt.Sprintf("%.3f MB/s", stat.ThroughputMBps),
+		Codes: fmt.Sprintf(
+			"%d / %d / %d / %d / %d / %d", stat.Code1xx, stat.Code2xx, stat.Code3xx, stat.Code4xx, stat.Code5xx,
+			stat.OtherCodes,
+		),
+	}
+
+	if stat.TotalRequests > 0 {
+		row.ErrorRate = fmt.Sprintf("%.2f%%", float64(stat.ErrorRequests)/float64(stat.TotalRequests)*100)
+	}
+
+	for _, key := range keys {
+		value := "-"
+
+		if percentile, ok := stat.Percentiles[statistics.PhaseTotal][key]; ok {
+			value = toTimeString(percentile)
+		}
+
+		row.Percentiles = append(row.Percentiles, value)
+	}
+
+	return row
+}
+
+func htmlErrors(stat statistics.SingleUrlStatistics) []htmlError {
+	results := make([]htmlError, 0, len(stat.Errors))
+
+	for _, result := range stat.Errors {
+		results = append(
+			results, htmlError{
+				Title:   strings.TrimSuffix(errorClassTitle(result.Class), ":"),
+				Message: result.Message,
+				Count:   result.Count,
+				Share:   fmt.Sprintf("%.2f%%", float64(result.Count)/float64(max(stat.TotalRequests, 1))*100),
+			},
+		)
+	}
+
+	return results
+}
+
+func htmlRequests(samples []statistics.RequestSample) []htmlRequest {
+	results := make([]htmlRequest, 0, len(samples))
+
+	for _, sample := range samples {
+		connection := "new"
+
+		if sample.Reused {
+			connection = "reused"
+		}
+
+		results = append(
+			results, htmlRequest{
+				StartedAt:   sample.StartedAt.Format("15:04:05.000"),
+				Status:      requestStatus(sample.StatusCode, sample.ErrorClass),
+				RemoteAddr:  sample.RemoteAddr,
+				Connection:  connection,
+				TraceId:     sample.TraceId,
+				SpanId:      sample.SpanId,
+				RequestTime: toTimeString(sample.Durations.Total.Total),
+			},
+		)
+	}
+
+	return results
+}
+
+// histogramChart groups the histogram buckets into histogramBars bars of logarithmic width,
+// so both the bulk of the requests and the slow outliers are visible
+func histogramChart(buckets []histogram.Bucket) *htmlChart {
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	from := math.Log(float64(max(buckets[0].From, int64(time.Microsecond))))
+	to := math.Log(float64(max(buckets[len(buckets)-1].To+1, int64(time.Microsecond)+1)))
+	width := (to - from) / histogramBars
+
+	if width <= 0 {
+		width = 1
+	}
+
+	counts := make([]int64, histogramBars)
+
+	for _, bucket := range buckets {
+		middle := float64(bucket.From+bucket.To) / 2
+		index := int((math.Log(max(middle, float64(time.Microsecond))) - from) / width)
+		counts[min(max(index, 0), histogramBars-1)] += bucket.Count
+	}
+
+	var maxCount int64
+
+	for _, count := range counts {
+		maxCount = max(maxCount, count)
+	}
+
+	chart := newHtmlChart()
+	chart.yGrid(float64(maxCount), func(value float64) string { return strconv.FormatInt(int64(value), 10) })
+
+	barWidth := chart.plotWidth() / histogramBars
+
+	for index, count := range counts {
+		barFrom := time.Duration(math.Exp(from + float64(index)*width))
+		barTo := time.Duration(math.Exp(from + float64(index+1)*width))
+		height := float64(count) / float64(max(maxCount, 1)) * chart.plotHeight()
+
+		chart.Bars = append(
+			chart.Bars, htmlChartBar{
+				X:      chartMarginLeft + float64(index)*barWidth + 1,
+				Y:      chartMarginTop + chart.plotHeight() - height,
+				Width:  barWidth - 2,
+				Height: height,
+				Class:  "bar",
+				Title:  fmt.Sprintf("%s - %s, requests: %d", toTimeString(barFrom), toTimeString(barTo), count),
+			},
+		)
+
+		if index%8 == 0 {
+			chart.xLabel(chartMarginLeft+float64(index)*barWidth, fmt.Sprintf("%.3f ms", toMilliseconds(barFrom)))
+		}
+	}
+
+	chart.xLabel(chartMarginLeft+chart.plotWidth(), fmt.Sprintf("%.3f ms", math.Exp(to)/float64(time.Millisecond)))
+
+	return chart
+}
+
+// percentileCurveChart plots the time per request of percentileCurvePoints, evenly spaced
+// so the tail percentiles get as much room as the median
+func percentileCurveChart(buckets []histogram.Bucket) *htmlChart {
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	values := histogram.FromBuckets(buckets)
+	maxValue := toMilliseconds(time.Duration(values.ValueAtPercentile(100)))
+
+	chart := newHtmlChart()
+	chart.yGrid(maxValue, func(value float64) string { return fmt.Sprintf("%.3f ms", value) })
+
+	step := chart.plotWidth() / float64(len(percentileCurvePoints)-1)
+	points := make([]string, 0, len(percentileCurvePoints))
+
+	for index, percentile := range percentileCurvePoints {
+		value := toMilliseconds(time.Duration(values.ValueAtPercentile(percentile)))
+		x := chartMarginLeft + float64(index)*step
+		y := chart.y(value, maxValue)
+
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+
+		chart.Bars = append(
+			chart.Bars, htmlChartBar{
+				X: x - 3, Y: y - 3, Width: 6, Height: 6,
+				Class: "point",
+				Title: fmt.Sprintf("p%s: %.3f ms", strconv.FormatFloat(percentile, 'f', -1, 64), value),
+			},
+		)
+
+		chart.xLabel(x, "p"+strconv.FormatFloat(percentile, 'f', -1, 64))
+	}
+
+	chart.Lines = append(chart.Lines, htmlChartLine{Points: strings.Join(points, " "), Class: "line"})
+
+	return chart
+}
+
+func throughputChart(timeline []statistics.TimelineBucket) *htmlChart {
+	if len(timeline) == 0 {
+		return nil
+	}
+
+	maxValue := 0.0
+
+	for _, bucket := range timeline {
+		maxValue = max(maxValue, bucket.RequestsPerSecond)
+	}
+
+	chart := newHtmlChart()
+	chart.yGrid(maxValue, func(value float64) string { return fmt.Sprintf("%.2f", value) })
+
+	barWidth := chart.plotWidth() / float64(len(timeline))
+
+	for index, bucket := range timeline {
+		x := chartMarginLeft + float64(index)*barWidth
+		height := chart.plotHeight() - (chart.y(bucket.RequestsPerSecond, maxValue) - chartMarginTop)
+		errorHeight := 0.0
+
+		if bucket.TotalRequests > 0 {
+			errorHeight = height * float64(bucket.ErrorRequests) / float64(bucket.TotalRequests)
+		}
+
+		title := fmt.Sprintf(
+			"%s: %.2f requests/s, %d requests, %d failed", bucket.Offset, bucket.RequestsPerSecond,
+			bucket.TotalRequests, bucket.ErrorRequests,
+		)
+
+		chart.Bars = append(
+			chart.Bars,
+			htmlChartBar{
+				X: x + 1, Y: chartMarginTop + chart.plotHeight() - height, Width: max(barWidth-2, 1),
+				Height: height - errorHeight, Class: "bar", Title: title,
+			},
+			htmlChartBar{
+				X: x + 1, Y: chartMarginTop + chart.plotHeight() - errorHeight, Width: max(barWidth-2, 1),
+				Height: errorHeight, Class: "error", Title: title,
+			},
+		)
+	}
+
+	chart.timeLabels(timeline)
+	chart.Legend = []htmlChartLine{{Class: "bar", Title: "Requests per second"}, {Class: "error", Title: "Failed"}}
+
+	return chart
+}
+
+func latencyChart(timeline []statistics.TimelineBucket) *htmlChart {
+	if len(timeline) == 0 {
+		return nil
+	}
+
+	series := []struct {
+		class, title string
+		value        func(bucket statistics.TimelineBucket) time.Duration
+	}{
+		{
+			"line", "Median",
+			func(bucket statistics.TimelineBucket) time.Duration { return bucket.RequestTimeMedian },
+		},
+		{
+			"line-p95", "p95",
+			func(bucket statistics.TimelineBucket) time.Duration { return bucket.Percentiles["p95"] },
+		},
+		{
+			"line-max", "Max",
+			func(bucket statistics.TimelineBucket) time.Duration { return bucket.RequestTimeMax },
+		},
+	}
+
+	if _, ok := timeline[0].Percentiles["p95"]; !ok {
+		series = append(series[:1], series[2:]...)
+	}
+
+	maxValue := 0.0
+
+	for _, bucket := range timeline {
+		maxValue = max(maxValue, toMilliseconds(bucket.RequestTimeMax))
+	}
+
+	chart := newHtmlChart()
+	chart.yGrid(maxValue, func(value float64) string { return fmt.Sprintf("%.3f ms", value) })
+
+	step := chart.plotWidth() / float64(len(timeline))
+
+	for _, line := range series {
+		points := make([]string, 0, len(timeline))
+
+		for index, bucket := range timeline {
+			if bucket.TotalRequests == 0 {
+				continue
+			}
+
+			x := chartMarginLeft + (float64(index)+0.5)*step
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x, chart.y(toMilliseconds(line.value(bucket)), maxValue)))
+		}
+
+		chart.Lines = append(chart.Lines, htmlChartLine{Points: strings.Join(points, " "), Class: line.class})
+		chart.Legend = append(chart.Legend, htmlChartLine{Class: line.class, Title: line.title})
+	}
+
+	chart.timeLabels(timeline)
+
+	return chart
+}
+
+// waterfallChart shows the average duration of every connection phase, one after another
+func waterfallChart(stat statistics.SingleUrlStatistics) *htmlChart {
+	phases := []struct {
+		phase    string
+		duration time.Duration
+	}{
+		{statistics.PhaseDNSLookup, stat.DNSLookupAvg},
+		{statistics.PhaseTCPConnection, stat.TCPConnectionAvg},
+		{statistics.PhaseTLSHandshake, stat.TLSHandshakeAvg},
+		{statistics.PhaseConnectionEstablished, stat.ConnectionEstablishedAvg},
+		{statistics.PhaseTTFB, stat.TTFBAvg},
+		{statistics.PhaseContentTransfer, stat.ContentTransferAvg},
+	}
+
+	var total time.Duration
+
+	for _, phase := range phases {
+		total += phase.duration
+	}
+
+	if total == 0 {
+		return nil
+	}
+
+	const rowHeight = 28
+	const labelWidth = 180
+
+	chart := &htmlChart{Width: chartWidth, Height: float64(len(phases)*rowHeight + chartMarginTop*2)}
+	plotWidth := float64(chartWidth - labelWidth - chartMarginRight - 100)
+
+	var offset time.Duration
+
+	for index, phase := range phases {
+		y := chartMarginTop + float64(index*rowHeight)
+		x := labelWidth + float64(offset)/float64(total)*plotWidth
+		title := strings.TrimSuffix(phaseTitles[phase.phase], ":")
+
+		chart.Labels = append(
+			chart.Labels,
+			htmlChartLabel{X: labelWidth - 10, Y: y + rowHeight/2 + 4, Anchor: "end", Text: title},
+			htmlChartLabel{
+				X:      x + float64(phase.duration)/float64(total)*plotWidth + 6,
+				Y:      y + rowHeight/2 + 4,
+				Anchor: "start",
+				Text:   toTimeString(phase.duration),
+			},
+		)
+
+		chart.Bars = append(
+			chart.Bars, htmlChartBar{
+				X:      x,
+				Y:      y + 4,
+				Width:  max(float64(phase.duration)/float64(total)*plotWidth, 1),
+				Height: rowHeight - 8,
+				Class:  "bar",
+				Title:  fmt.Sprintf("%s: %s", title, toTimeString(phase.duration)),
+			},
+		)
+
+		offset += phase.duration
+	}
+
+	return chart
+}
+
+func newHtmlChart() *htmlChart {
+	return &htmlChart{Width: chartWidth, Height: chartHeight}
+}
+
+func (chart *htmlChart) plotWidth() float64 {
+	return chart.Width - chartMarginLeft - chartMarginRight
+}
+
+func (chart *htmlChart) plotHeight() float64 {
+	return chart.Height - chartMarginTop - chartMarginBottom
+}
+
+func (chart *htmlChart) y(value float64, maxValue float64) float64 {
+	if maxValue <= 0 {
+		return chartMarginTop + chart.plotHeight()
+	}
+
+	return chartMarginTop + chart.plotHeight() - value/maxValue*chart.plotHeight()
+}
+
+// yGrid adds horizontal grid lines with the values of the y axis
+func (chart *htmlChart) yGrid(maxValue float64, format func(value float64) string) {
+	const lines = 4
+
+	for line := 0; line <= lines; line++ {
+		value := maxValue * float64(line) / lines
+		y := chart.y(value, maxValue)
+
+		if maxValue <= 0 {
+			y = chartMarginTop + chart.plotHeight() - float64(line)/lines*chart.plotHeight()
+		}
+
+		chart.Grid = append(
+			chart.Grid, htmlChartLabel{X: chartMarginLeft - 6, Y: y, Anchor: "end", Text: format(value)},
+		)
+	}
+}
+
+func (chart *htmlChart) xLabel(x float64, text string) {
+	chart.Labels = append(
+		chart.Labels, htmlChartLabel{X: x, Y: chart.Height - chartMarginBottom + 18, Anchor: "middle", Text: text},
+	)
+}
+
+func (chart *htmlChart) timeLabels(timeline []statistics.TimelineBucket) {
+	step := chart.plotWidth() / float64(len(timeline))
+	every := max(len(timeline)/8, 1)
+
+	for index := 0; index < len(timeline); index += every {
+		chart.xLabel(chartMarginLeft+(float64(index)+0.5)*step, timeline[index].Offset.String())
+	}
+}
@@ -0,0 +1,94 @@
+package formatter
+
+import (
+	"bytes"
+	"github.com/vpominchuk/wmetrics/src/histogram"
+	"github.com/vpominchuk/wmetrics/src/statistics"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintHtmlResults(t *testing.T) {
+	requestTimes := histogram.New()
+
+	for value := 1; value <= 100; value++ {
+		requestTimes.Record(int64(value) * int64(time.Millisecond))
+	}
+
+	stat := statistics.SingleUrlStatistics{
+		TotalTime:         2 * time.Second,
+		TotalRequests:     100,
+		SuccessRequests:   99,
+		ErrorRequests:     1,
+		Code1xx:           1,
+		Code2xx:           98,
+		Code5xx:           1,
+		RequestTimeAvg:    50 * time.Millisecond,
+		RequestTimeMedian: 50 * time.Millisecond,
+		RequestTimeMax:    100 * time.Millisecond,
+		TTFBAvg:           30 * time.Millisecond,
+		Percentiles: statistics.Percentiles{
+			statistics.PhaseTotal: {"p50": 50 * time.Millisecond, "p99": 99 * time.Millisecond},
+		},
+		Histograms: map[string][]histogram.Bucket{statistics.PhaseTotal: requestTimes.Buckets()},
+		Timeline: []statistics.TimelineBucket{
+			{Duration: time.Second, RequestsPerSecond: 60, TotalRequests: 60, RequestTimeMedian: 40 * time.Millisecond},
+			{
+				Offset: time.Second, Duration: time.Second, RequestsPerSecond: 40, TotalRequests: 40, ErrorRequests: 1,
+				RequestTimeMedian: 60 * time.Millisecond,
+			},
+		},
+		Errors: []statistics.ErrorResult{
+			{Class: tester.ErrorClassHttpCode, Message: "<500 Internal Server Error>", Count: 1},
+		},
+		SlowestRequests: []statistics.RequestSample{{StatusCode: 200, TraceId: "4bf92f3577b34da6a3ce929d0e0e4736"}},
+	}
+
+	requestUrl, _ := url.Parse("http://localhost/")
+
+	parameters := tester.Parameters{
+		Resources:   []tester.Resource{{Url: requestUrl}},
+		Method:      http.MethodGet,
+		Requests:    100,
+		Concurrency: 4,
+		Percentiles: []float64{50, 99},
+	}
+
+	var output bytes.Buffer
+
+	err := PrintHtmlResults(&output, parameters, statistics.Statistics{"http://localhost/": stat}, "")
+
+	if err != nil {
+		t.Fatalf("PrintHtmlResults() returned an error: %v", err)
+	}
+
+	report := output.String()
+
+	for _, want := range []string{
+		"<html",
+		"</html>",
+		"<h2>http://localhost/</h2>",
+		"<svg",
+		"1 / 98 / 0 / 0 / 1 / 0",
+		"50.00",
+		"p99",
+		"4bf92f3577b34da6a3ce929d0e0e4736",
+		"&lt;500 Internal Server Error&gt;",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("the report does not contain %q", want)
+		}
+	}
+
+	if strings.Contains(report, "All URLs") {
+		t.Errorf("the report of a single URL contains the statistics of all URLs")
+	}
+
+	if strings.Contains(report, "<500") {
+		t.Errorf("the error message is not escaped")
+	}
+}
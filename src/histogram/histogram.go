@@ -0,0 +1,187 @@
+package histogram
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// subBucketBits defines the precision of the histogram. Values are grouped into buckets
+// whose width is at most 1/2^(subBucketBits-1) of the value, i.e. the relative error is below 0.2%.
+const (
+	subBucketBits      = 10
+	subBucketCount     = 1 << subBucketBits
+	subBucketHalfCount = subBucketCount / 2
+)
+
+const rankTolerance = 1e-9
+
+type Bucket struct {
+	From,
+	To,
+	Count int64
+}
+
+// Histogram is a high dynamic range histogram. It uses an exact bucket for every value
+// below subBucketCount and log-linear buckets above, so its memory usage is bounded
+// no matter how many values are recorded. Only non-empty buckets are stored.
+type Histogram struct {
+	counts map[int]int64
+	count  int64
+	sum    float64
+	min    int64
+	max    int64
+}
+
+func New() *Histogram {
+	return &Histogram{
+		counts: make(map[int]int64),
+	}
+}
+
+// FromBuckets restores a histogram from exported buckets, e.g. to merge results of several runs.
+// The exact minimum, maximum and mean are not exported, so they are approximated by the buckets.
+func FromBuckets(buckets []Bucket) *Histogram {
+	histogram := New()
+
+	for _, bucket := range buckets {
+		histogram.RecordValues(bucket.From, bucket.Count)
+	}
+
+	return histogram
+}
+
+// Clone returns a copy of the histogram, which can be recorded to independently
+func (histogram *Histogram) Clone() *Histogram {
+	clone := *histogram
+	clone.counts = make(map[int]int64, len(histogram.counts))
+
+	for index, count := range histogram.counts {
+		clone.counts[index] = count
+	}
+
+	return &clone
+}
+
+func (histogram *Histogram) Record(value int64) {
+	histogram.RecordValues(value, 1)
+}
+
+func (histogram *Histogram) RecordValues(value int64, count int64) {
+	if count <= 0 {
+		return
+	}
+
+	value = max(value, 0)
+
+	if histogram.count == 0 {
+		histogram.min = value
+		histogram.max = value
+	} else {
+		histogram.min = min(histogram.min, value)
+		histogram.max = max(histogram.max, value)
+	}
+
+	histogram.counts[bucketIndex(value)] += count
+	histogram.count += count
+	histogram.sum += float64(value) * float64(count)
+}
+
+func (histogram *Histogram) Count() int64 {
+	return histogram.count
+}
+
+func (histogram *Histogram) Min() int64 {
+	return histogram.min
+}
+
+func (histogram *Histogram) Max() int64 {
+	return histogram.max
+}
+
+func (histogram *Histogram) Mean() float64 {
+	if histogram.count == 0 {
+		return 0
+	}
+
+	return histogram.sum / float64(histogram.count)
+}
+
+// ValueAtPercentile uses the nearest-rank method and returns the highest value
+// equivalent to the bucket of the rank, limited by the recorded minimum and maximum
+func (histogram *Histogram) ValueAtPercentile(percentile float64) int64 {
+	if histogram.count == 0 {
+		return 0
+	}
+
+	// the tolerance keeps e.g. 99.9% of 1000 values at the rank 999, the float product is slightly above it
+	rank := int64(math.Ceil(percentile/100*float64(histogram.count) - rankTolerance))
+	rank = min(max(rank, 1), histogram.count)
+
+	var total int64
+
+	for _, index := range histogram.sortedIndexes() {
+		total += histogram.counts[index]
+
+		if total >= rank {
+			_, to := bucketRange(index)
+			return min(max(to, histogram.min), histogram.max)
+		}
+	}
+
+	return histogram.max
+}
+
+// Buckets returns the non-empty buckets in ascending order
+func (histogram *Histogram) Buckets() []Bucket {
+	indexes := histogram.sortedIndexes()
+	buckets := make([]Bucket, 0, len(indexes))
+
+	for _, index := range indexes {
+		from, to := bucketRange(index)
+
+		buckets = append(
+			buckets, Bucket{
+				From:  from,
+				To:    to,
+				Count: histogram.counts[index],
+			},
+		)
+	}
+
+	return buckets
+}
+
+func (histogram *Histogram) sortedIndexes() []int {
+	indexes := make([]int, 0, len(histogram.counts))
+
+	for index := range histogram.counts {
+		indexes = append(indexes, index)
+	}
+
+	slices.Sort(indexes)
+
+	return indexes
+}
+
+func bucketIndex(value int64) int {
+	if value < subBucketCount {
+		return int(value)
+	}
+
+	shift := bits.Len64(uint64(value)) - subBucketBits
+	subBucket := int(value >> shift)
+
+	return subBucketCount + (shift-1)*subBucketHalfCount + subBucket - subBucketHalfCount
+}
+
+func bucketRange(index int) (int64, int64) {
+	if index < subBucketCount {
+		return int64(index), int64(index)
+	}
+
+	shift := (index-subBucketCount)/subBucketHalfCount + 1
+	subBucket := int64((index-subBucketCount)%subBucketHalfCount + subBucketHalfCount)
+
+	return subBucket << shift, (subBucket+1)<<shift - 1
+}
@@ -0,0 +1,157 @@
+package histogram
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBucketIndex(t *testing.T) {
+	tests := []struct {
+		value    int64
+		index    int
+		from, to int64
+	}{
+		{0, 0, 0, 0},
+		{1, 1, 1, 1},
+		{1023, 1023, 1023, 1023},
+		{1024, 1024, 1024, 1025},
+		{1025, 1024, 1024, 1025},
+		{1026, 1025, 1026, 1027},
+		{2047, 1535, 2046, 2047},
+		{2048, 1536, 2048, 2051},
+		{4096, 2048, 4096, 4103},
+	}
+
+	for _, test := range tests {
+		index := bucketIndex(test.value)
+
+		if index != test.index {
+			t.Errorf("bucketIndex(%d) = %d, want %d", test.value, index, test.index)
+		}
+
+		from, to := bucketRange(index)
+
+		if from != test.from || to != test.to {
+			t.Errorf("bucketRange(%d) = %d..%d, want %d..%d", index, from, to, test.from, test.to)
+		}
+	}
+}
+
+func TestBucketPrecision(t *testing.T) {
+	previous := -1
+
+	for _, value := range []int64{
+		1, 999, 1024, 5000, 65535, 1_000_000, 123_456_789, 30_000_000_000, math.MaxInt64 / 4,
+	} {
+		index := bucketIndex(value)
+		from, to := bucketRange(index)
+
+		if value < from || value > to {
+			t.Errorf("value %d is outside of its bucket %d..%d", value, from, to)
+		}
+
+		if float64(to-from) > float64(value)/subBucketHalfCount {
+			t.Errorf("bucket %d..%d of value %d is wider than the precision", from, to, value)
+		}
+
+		if index <= previous {
+			t.Errorf("bucketIndex(%d) = %d is not above the index of a smaller value %d", value, index, previous)
+		}
+
+		previous = index
+	}
+}
+
+func TestValueAtPercentile(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     []int64
+		percentile float64
+		want       int64
+	}{
+		{"empty", nil, 50, 0},
+		{"single value", []int64{42}, 99, 42},
+		{"median", sequence(1, 100), 50, 50},
+		{"p90", sequence(1, 100), 90, 90},
+		{"p99.9", sequence(1, 1000), 99.9, 999},
+		{"p0 is the minimum", sequence(5, 100), 0, 5},
+		{"p100 is the maximum", sequence(1, 100), 100, 100},
+		{"limited by the maximum", []int64{1_000_001}, 50, 1_000_001},
+		{"limited by the minimum", []int64{1_000_001, 1_000_001}, 0, 1_000_001},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				histogram := New()
+
+				for _, value := range test.values {
+					histogram.Record(value)
+				}
+
+				if got := histogram.ValueAtPercentile(test.percentile); got != test.want {
+					t.Errorf("ValueAtPercentile(%g) = %d, want %d", test.percentile, got, test.want)
+				}
+			},
+		)
+	}
+}
+
+func TestValueAtPercentileLargeValues(t *testing.T) {
+	histogram := New()
+
+	for value := int64(1); value <= 10_000; value++ {
+		histogram.Record(value * 1_000_000)
+	}
+
+	for _, percentile := range []float64{50, 95, 99} {
+		want := float64(percentile) * 100 * 1_000_000
+		got := float64(histogram.ValueAtPercentile(percentile))
+
+		if math.Abs(got-want)/want > 0.002 {
+			t.Errorf("ValueAtPercentile(%g) = %.0f, want %.0f within 0.2%%", percentile, got, want)
+		}
+	}
+}
+
+func TestFromBuckets(t *testing.T) {
+	histogram := New()
+	histogram.RecordValues(10, 3)
+	histogram.RecordValues(5000, 2)
+
+	restored := FromBuckets(histogram.Buckets())
+
+	if restored.Count() != 5 {
+		t.Errorf("Count() = %d, want 5", restored.Count())
+	}
+
+	if got, want := restored.ValueAtPercentile(50), histogram.ValueAtPercentile(50); got != want {
+		t.Errorf("ValueAtPercentile(50) = %d, want %d", got, want)
+	}
+}
+
+func TestClone(t *testing.T) {
+	histogram := New()
+	histogram.RecordValues(10, 3)
+
+	clone := histogram.Clone()
+	clone.Record(5000)
+
+	if histogram.Count() != 3 || histogram.Max() != 10 {
+		t.Errorf("the original histogram changed: count %d, max %d", histogram.Count(), histogram.Max())
+	}
+
+	if clone.Count() != 4 || clone.Min() != 10 || clone.Max() != 5000 || clone.Mean() != 1257.5 {
+		t.Errorf("clone count %d, min %d, max %d, mean %g", clone.Count(), clone.Min(), clone.Max(), clone.Mean())
+	}
+}
+
+func sequence(from, to int64) []int64 {
+	values := make([]int64, 0, to-from+1)
+
+	for value := from; value <= to; value++ {
+		values = append(values, value)
+	}
+
+	return values
+}
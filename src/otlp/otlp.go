@@ -0,0 +1,360 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vpominchuk/wmetrics/src/app"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tracesPath = "/v1/traces"
+
+	// maxBatchSize is the maximum number of spans sent in one export request
+	maxBatchSize = 512
+
+	// queueSize is the number of spans waiting for the export, further spans are dropped
+	queueSize = 8192
+
+	flushInterval = time.Second
+
+	spanKindClient  = 3
+	statusCodeError = 2
+)
+
+// Exporter sends one client span per request to an OTLP/HTTP endpoint in the JSON encoding.
+// The span has the trace and span id of the traceparent header of the request and an event
+// for every connection phase. The spans are exported in the background, in batches.
+type Exporter struct {
+	url    string
+	method string
+	client *http.Client
+
+	spans chan span
+	done  chan bool
+
+	// dropped is counted by Consume, failed and lastErr by the export goroutine until done is closed
+	dropped,
+	failed int
+	lastErr error
+}
+
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []attribute `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type span struct {
+	TraceId           string      `json:"traceId"`
+	SpanId            string      `json:"spanId"`
+	ParentSpanId      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []attribute `json:"attributes"`
+	Events            []event     `json:"events,omitempty"`
+	Status            status      `json:"status"`
+}
+
+type event struct {
+	TimeUnixNano string `json:"timeUnixNano"`
+	Name         string `json:"name"`
+}
+
+type status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type attribute struct {
+	Key   string         `json:"key"`
+	Value attributeValue `json:"value"`
+}
+
+// attributeValue is the JSON form of AnyValue, 64-bit integers are encoded as strings
+type attributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+// TracesUrl returns the traces endpoint of the collector, the /v1/traces path is added to a base URL
+func TracesUrl(endpoint string) string {
+	if strings.HasSuffix(endpoint, tracesPath) {
+		return endpoint
+	}
+
+	return strings.TrimSuffix(endpoint, "/") + tracesPath
+}
+
+func New(endpoint string, method string) *Exporter {
+	return &Exporter{
+		url:    TracesUrl(endpoint),
+		method: method,
+		client: &http.Client{Timeout: 10 * time.Second},
+		spans:  make(chan span, queueSize),
+		done:   make(chan bool),
+	}
+}
+
+func (exporter *Exporter) Start() {
+	go func() {
+		defer close(exporter.done)
+
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+
+		batch := make([]span, 0, maxBatchSize)
+
+		for {
+			select {
+			case span, ok := <-exporter.spans:
+				if !ok {
+					exporter.export(batch)
+					return
+				}
+
+				batch = append(batch, span)
+
+				if len(batch) >= maxBatchSize {
+					exporter.export(batch)
+					batch = batch[:0]
+				}
+			case <-ticker.C:
+				exporter.export(batch)
+				batch = batch[:0]
+			}
+		}
+	}()
+}
+
+// Consume queues the span of the request. Missed dispatches were never sent, so they have no span.
+func (exporter *Exporter) Consume(result tester.MeasurementResult) {
+	if result.RequestResult.Trace.TraceId == "" || result.RequestResult.Dispatch.Missed {
+		return
+	}
+
+	select {
+	case exporter.spans <- exporter.span(result):
+	default:
+		exporter.dropped++
+	}
+}
+
+// Close exports the queued spans and returns an error if any of the spans could not be exported
+func (exporter *Exporter) Close() error {
+	close(exporter.spans)
+	<-exporter.done
+
+	exporter.client.CloseIdleConnections()
+
+	var errs []error
+
+	if exporter.failed > 0 {
+		errs = append(
+			errs, fmt.Errorf("%d span exports failed, last error: %w", exporter.failed, exporter.lastErr),
+		)
+	}
+
+	if exporter.dropped > 0 {
+		errs = append(errs, fmt.Errorf("%d spans dropped, the export is too slow", exporter.dropped))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (exporter *Exporter) export(spans []span) {
+	if len(spans) == 0 {
+		return
+	}
+
+	request := exportRequest{
+		ResourceSpans: []resourceSpans{
+			{
+				Resource: resource{
+					Attributes: []attribute{
+						stringAttribute("service.name", "wmetrics"),
+						stringAttribute("service.version", app.GitTag),
+					},
+				},
+				ScopeSpans: []scopeSpans{
+					{
+						Scope: scope{Name: "wmetrics", Version: app.GitTag},
+						Spans: spans,
+					},
+				},
+			},
+		},
+	}
+
+	body, err := json.Marshal(request)
+
+	if err == nil {
+		err = exporter.post(body)
+	}
+
+	if err != nil {
+		exporter.failed++
+		exporter.lastErr = err
+	}
+}
+
+func (exporter *Exporter) post(body []byte) error {
+	response, err := exporter.client.Post(exporter.url, "application/json", bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
+
+func (exporter *Exporter) span(result tester.MeasurementResult) span {
+	requestResult := result.RequestResult
+	timing := requestResult.Timing
+
+	attributes := []attribute{
+		stringAttribute("http.request.method", exporter.method),
+		stringAttribute("url.full", requestResult.Resource.Url.String()),
+		stringAttribute("server.address", requestResult.Resource.Url.Hostname()),
+		intAttribute("wmetrics.worker.id", int64(requestResult.WorkerId)),
+		boolAttribute("wmetrics.connection.reused", requestResult.Connection.Reused),
+	}
+
+	if port := requestResult.Resource.Url.Port(); port != "" {
+		portNumber, _ := strconv.ParseInt(port, 10, 64)
+		attributes = append(attributes, intAttribute("server.port", portNumber))
+	}
+
+	if requestResult.StatusCode > 0 {
+		attributes = append(attributes, intAttribute("http.response.status_code", int64(requestResult.StatusCode)))
+	}
+
+	if host, _, err := net.SplitHostPort(requestResult.Connection.RemoteAddr); err == nil {
+		attributes = append(attributes, stringAttribute("network.peer.address", host))
+	}
+
+	if requestResult.TLS.TLSVersion != "" {
+		attributes = append(attributes, stringAttribute("tls.protocol.version", requestResult.TLS.TLSVersion))
+	}
+
+	if requestResult.Dispatch.Warmup {
+		attributes = append(attributes, boolAttribute("wmetrics.warmup", true))
+	}
+
+	spanStatus := status{}
+
+	// a client span is failed by an error or a 4xx and 5xx response, as in the HTTP semantic conventions
+	if err := result.PrimaryError(); err != nil {
+		attributes = append(attributes, stringAttribute("error.type", tester.ClassifyError(err)))
+		spanStatus = status{Code: statusCodeError, Message: err.Error()}
+	} else if requestResult.StatusCode >= 400 {
+		attributes = append(attributes, stringAttribute("error.type", strconv.Itoa(requestResult.StatusCode)))
+		spanStatus = status{Code: statusCodeError}
+	}
+
+	start := result.StartedAt()
+
+	if !timing.Start.IsZero() {
+		start = timing.Start
+	}
+
+	return span{
+		TraceId:           requestResult.Trace.TraceId,
+		SpanId:            requestResult.Trace.SpanId,
+		ParentSpanId:      requestResult.Trace.ParentSpanId,
+		Name:              exporter.method,
+		Kind:              spanKindClient,
+		StartTimeUnixNano: unixNano(start),
+		EndTimeUnixNano:   unixNano(result.CompletedAt()),
+		Attributes:        attributes,
+		Events:            phaseEvents(requestResult),
+		Status:            spanStatus,
+	}
+}
+
+// phaseEvents marks the start and the end of the connection phases. Reused connections
+// have no DNS lookup, connect and TLS handshake, so only the phases which took time are included.
+func phaseEvents(result tester.RequestResult) []event {
+	timing := result.Timing
+	events := make([]event, 0, 10)
+
+	add := func(name string, moment time.Time) {
+		if !moment.IsZero() {
+			events = append(events, event{TimeUnixNano: unixNano(moment), Name: name})
+		}
+	}
+
+	if result.Durations.DNSLookup.Duration > 0 {
+		add("dns.start", timing.DNSStart)
+		add("dns.done", timing.DNSEnd)
+	}
+
+	if result.Durations.TCPConnection.Duration > 0 {
+		add("connect.start", timing.DNSEnd)
+		add("connect.done", timing.TCPConnect)
+	}
+
+	if result.Durations.TLSHandshake.Duration > 0 {
+		add("tls.start", timing.TLSHandshakeStart)
+		add("tls.done", timing.TLSHandshakeEnd)
+	}
+
+	add("request.sent", timing.RequestSent)
+	add("response.first_byte", timing.TTFB)
+	add("response.done", timing.TotalTime)
+
+	return events
+}
+
+func unixNano(moment time.Time) string {
+	return strconv.FormatInt(moment.UnixNano(), 10)
+}
+
+func stringAttribute(key string, value string) attribute {
+	return attribute{Key: key, Value: attributeValue{StringValue: &value}}
+}
+
+func intAttribute(key string, value int64) attribute {
+	formatted := strconv.FormatInt(value, 10)
+	return attribute{Key: key, Value: attributeValue{IntValue: &formatted}}
+}
+
+func boolAttribute(key string, value bool) attribute {
+	return attribute{Key: key, Value: attributeValue{BoolValue: &value}}
+}
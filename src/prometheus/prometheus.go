@@ -0,0 +1,319 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsPath is the path the metrics are served at
+const MetricsPath = "/metrics"
+
+// durationBuckets are the upper bounds of the latency histograms in seconds
+var durationBuckets = []float64{
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60,
+}
+
+var statusClasses = []string{"1xx", "2xx", "3xx", "4xx", "5xx", "other"}
+
+// Exporter collects live metrics of the test and serves them in the Prometheus text format.
+// Warm-up requests are excluded from the counters and histograms, but they are counted as in flight.
+type Exporter struct {
+	mutex  sync.Mutex
+	urls   map[string]*urlMetrics
+	server *http.Server
+}
+
+type urlMetrics struct {
+	requests,
+	failedRequests,
+	missedDispatches,
+	bytesReceived int64
+	inFlight int64
+	statuses map[string]int64
+	errors   map[string]int64
+	duration *histogram
+	ttfb     *histogram
+}
+
+type histogram struct {
+	counts []int64
+	count  int64
+	sum    float64
+}
+
+func New() *Exporter {
+	return &Exporter{
+		urls: make(map[string]*urlMetrics),
+	}
+}
+
+// Start listens on the address and serves the metrics in the background until Close is called
+func (exporter *Exporter) Start(address string) error {
+	listener, err := net.Listen("tcp", address)
+
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, exporter)
+
+	exporter.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		_ = exporter.server.Serve(listener)
+	}()
+
+	return nil
+}
+
+// Close stops the listener, waiting a moment for the scrapes in progress
+func (exporter *Exporter) Close() error {
+	if exporter.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := exporter.server.Shutdown(ctx)
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return exporter.server.Close()
+	}
+
+	return err
+}
+
+func (exporter *Exporter) Consume(result tester.MeasurementResult) {
+	if result.RequestResult.Resource.Url == nil || result.RequestResult.Dispatch.Warmup {
+		return
+	}
+
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	metrics := exporter.get(result.RequestResult.Resource.Url.String())
+
+	if result.RequestResult.Dispatch.Missed {
+		metrics.missedDispatches++
+		return
+	}
+
+	metrics.requests++
+	metrics.bytesReceived += result.RequestResult.BytesReceived.Headers + result.RequestResult.BytesReceived.Body
+
+	if result.RequestResult.StatusCode > 0 {
+		metrics.statuses[statusClass(result.RequestResult.StatusCode)]++
+	}
+
+	if err := result.PrimaryError(); err != nil {
+		metrics.failedRequests++
+		metrics.errors[tester.ClassifyError(err)]++
+
+		return
+	}
+
+	metrics.duration.observe(result.RequestResult.Durations.Total.Total)
+	metrics.ttfb.observe(result.RequestResult.Durations.TTFB.Duration)
+}
+
+func (exporter *Exporter) RequestStarted(resource tester.Resource) {
+	exporter.mutex.Lock()
+	exporter.get(resource.Url.String()).inFlight++
+	exporter.mutex.Unlock()
+}
+
+func (exporter *Exporter) RequestFinished(resource tester.Resource) {
+	exporter.mutex.Lock()
+	exporter.get(resource.Url.String()).inFlight--
+	exporter.mutex.Unlock()
+}
+
+func (exporter *Exporter) ServeHTTP(writer http.ResponseWriter, _ *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	exporter.write(writer)
+}
+
+func (exporter *Exporter) get(url string) *urlMetrics {
+	metrics, ok := exporter.urls[url]
+
+	if !ok {
+		metrics = &urlMetrics{
+			statuses: make(map[string]int64),
+			errors:   make(map[string]int64),
+			duration: newHistogram(),
+			ttfb:     newHistogram(),
+		}
+
+		exporter.urls[url] = metrics
+	}
+
+	return metrics
+}
+
+func (exporter *Exporter) write(output io.Writer) {
+	urls := make([]string, 0, len(exporter.urls))
+
+	for url := range exporter.urls {
+		urls = append(urls, url)
+	}
+
+	slices.Sort(urls)
+
+	writeHeader(output, "wmetrics_requests_total", "counter", "Completed requests, failed ones included.")
+
+	for _, url := range urls {
+		count := exporter.urls[url].requests
+		writeSample(output, "wmetrics_requests_total", labels("url", url), float64(count))
+	}
+
+	writeHeader(output, "wmetrics_requests_failed_total", "counter", "Failed requests.")
+
+	for _, url := range urls {
+		count := exporter.urls[url].failedRequests
+		writeSample(output, "wmetrics_requests_failed_total", labels("url", url), float64(count))
+	}
+
+	writeHeader(output, "wmetrics_responses_total", "counter", "Responses by HTTP status class.")
+
+	for _, url := range urls {
+		for _, class := range statusClasses {
+			count := exporter.urls[url].statuses[class]
+			writeSample(output, "wmetrics_responses_total", labels("url", url, "code", class), float64(count))
+		}
+	}
+
+	writeHeader(output, "wmetrics_errors_total", "counter", "Failed requests by error class.")
+
+	for _, url := range urls {
+		classes := make([]string, 0, len(exporter.urls[url].errors))
+
+		for class := range exporter.urls[url].errors {
+			classes = append(classes, class)
+		}
+
+		slices.Sort(classes)
+
+		for _, class := range classes {
+			count := exporter.urls[url].errors[class]
+			writeSample(output, "wmetrics_errors_total", labels("url", url, "class", class), float64(count))
+		}
+	}
+
+	writeHeader(output, "wmetrics_missed_dispatches_total", "counter", "Requests not sent in time at the constant rate.")
+
+	for _, url := range urls {
+		count := exporter.urls[url].missedDispatches
+		writeSample(output, "wmetrics_missed_dispatches_total", labels("url", url), float64(count))
+	}
+
+	writeHeader(output, "wmetrics_received_bytes_total", "counter", "Bytes received over the wire, headers included.")
+
+	for _, url := range urls {
+		count := exporter.urls[url].bytesReceived
+		writeSample(output, "wmetrics_received_bytes_total", labels("url", url), float64(count))
+	}
+
+	writeHeader(output, "wmetrics_requests_in_flight", "gauge", "Requests sent and waiting for the response.")
+
+	for _, url := range urls {
+		count := exporter.urls[url].inFlight
+		writeSample(output, "wmetrics_requests_in_flight", labels("url", url), float64(count))
+	}
+
+	writeHeader(output, "wmetrics_request_duration_seconds", "histogram", "Time per successful request.")
+
+	for _, url := range urls {
+		exporter.urls[url].duration.write(output, "wmetrics_request_duration_seconds", url)
+	}
+
+	writeHeader(
+		output, "wmetrics_ttfb_seconds", "histogram",
+		"Time from the established connection to the first response byte of successful requests.",
+	)
+
+	for _, url := range urls {
+		exporter.urls[url].ttfb.write(output, "wmetrics_ttfb_seconds", url)
+	}
+}
+
+func newHistogram() *histogram {
+	return &histogram{
+		counts: make([]int64, len(durationBuckets)),
+	}
+}
+
+func (histogram *histogram) observe(duration time.Duration) {
+	seconds := duration.Seconds()
+
+	for index, bound := range durationBuckets {
+		if seconds <= bound {
+			histogram.counts[index]++
+		}
+	}
+
+	histogram.count++
+	histogram.sum += seconds
+}
+
+func (histogram *histogram) write(output io.Writer, name string, url string) {
+	for index, bound := range durationBuckets {
+		writeSample(
+			output, name+"_bucket", labels("url", url, "le", formatValue(bound)), float64(histogram.counts[index]),
+		)
+	}
+
+	writeSample(output, name+"_bucket", labels("url", url, "le", "+Inf"), float64(histogram.count))
+	writeSample(output, name+"_sum", labels("url", url), histogram.sum)
+	writeSample(output, name+"_count", labels("url", url), float64(histogram.count))
+}
+
+func statusClass(statusCode int) string {
+	if statusCode >= 100 && statusCode < 600 {
+		return strconv.Itoa(statusCode/100) + "xx"
+	}
+
+	return "other"
+}
+
+func writeHeader(output io.Writer, name string, metricType string, help string) {
+	fmt.Fprintf(output, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func writeSample(output io.Writer, name string, labels string, value float64) {
+	fmt.Fprintf(output, "%s{%s} %s\n", name, labels, formatValue(value))
+}
+
+// labels formats name and value pairs, escaping the values as required by the text format
+func labels(pairs ...string) string {
+	formatted := make([]string, 0, len(pairs)/2)
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	for index := 0; index+1 < len(pairs); index += 2 {
+		formatted = append(formatted, pairs[index]+`="`+escaper.Replace(pairs[index+1])+`"`)
+	}
+
+	return strings.Join(formatted, ",")
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
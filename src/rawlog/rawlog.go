@@ -0,0 +1,83 @@
+package rawlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"os"
+)
+
+// Run holds the settings of the test the statistics depend on, so a report can rebuild
+// the per-stage, assertion and threshold sections. It is written as the first line of the log.
+type Run struct {
+	Stages     []tester.Stage `json:",omitempty"`
+	Assertions []string       `json:",omitempty"`
+	Thresholds []string       `json:",omitempty"`
+}
+
+// header is the first line of the log, the results follow one per line
+type header struct {
+	Run *Run
+}
+
+// Writer writes every measurement result as one JSON object per line (JSON Lines)
+type Writer struct {
+	file   *os.File
+	writer *bufio.Writer
+	err    error
+}
+
+func New(fileName string, run Run) (*Writer, error) {
+	file, err := os.Create(fileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	log := &Writer{
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}
+
+	data, err := json.Marshal(header{Run: &run})
+
+	if err == nil {
+		_, err = log.writer.Write(append(data, '\n'))
+	}
+
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return log, nil
+}
+
+// Consume writes the result. The first error stops the log and is returned by Close.
+func (log *Writer) Consume(result tester.MeasurementResult) {
+	if log.err != nil {
+		return
+	}
+
+	data, err := result.ToJson()
+
+	if err == nil {
+		_, err = log.writer.Write(append(data, '\n'))
+	}
+
+	log.err = err
+}
+
+func (log *Writer) Close() error {
+	err := log.writer.Flush()
+
+	if closeErr := log.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if log.err != nil {
+		return log.err
+	}
+
+	return err
+}
@@ -0,0 +1,101 @@
+package rawlog
+
+import (
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "results.jsonl")
+	requestUrl, _ := url.Parse("http://localhost/")
+
+	run := Run{
+		Stages:     []tester.Stage{{Duration: time.Minute, Target: 10}, {Duration: 30 * time.Second}},
+		Assertions: []string{"status:2xx"},
+		Thresholds: []string{"p95<250ms"},
+	}
+
+	log, err := New(fileName, run)
+
+	if err != nil {
+		t.Fatalf("New() returned an error: %v", err)
+	}
+
+	for _, statusCode := range []int{200, 503} {
+		log.Consume(
+			tester.MeasurementResult{
+				RequestResult: tester.RequestResult{
+					Resource:   tester.Resource{Url: requestUrl},
+					StatusCode: statusCode,
+					Dispatch:   tester.Dispatch{Stage: 2},
+				},
+			},
+		)
+	}
+
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close() returned an error: %v", err)
+	}
+
+	statusCodes := make([]int, 0, 2)
+
+	restored, err := Read(
+		fileName, func(result tester.MeasurementResult) {
+			statusCodes = append(statusCodes, result.RequestResult.StatusCode)
+
+			if result.RequestResult.Dispatch.Stage != 2 {
+				t.Errorf("stage = %d, want 2", result.RequestResult.Dispatch.Stage)
+			}
+		},
+	)
+
+	if err != nil {
+		t.Fatalf("Read() returned an error: %v", err)
+	}
+
+	if !reflect.DeepEqual(restored, run) {
+		t.Errorf("Read() run = %+v, want %+v", restored, run)
+	}
+
+	if !reflect.DeepEqual(statusCodes, []int{200, 503}) {
+		t.Errorf("Read() results with status codes %v, want [200 503]", statusCodes)
+	}
+}
+
+func TestReadWithoutRun(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "results.jsonl")
+	data := `{"Url":"http://localhost/","StatusCode":200}` + "\n\n" + `{"Url":"http://localhost/","StatusCode":404}` + "\n"
+
+	if err := os.WriteFile(fileName, []byte(data), 0o644); err != nil {
+		t.Fatalf("unable to write %s: %v", fileName, err)
+	}
+
+	results := 0
+
+	run, err := Read(fileName, func(result tester.MeasurementResult) { results++ })
+
+	if err != nil {
+		t.Fatalf("Read() returned an error: %v", err)
+	}
+
+	if !reflect.DeepEqual(run, Run{}) || results != 2 {
+		t.Errorf("Read() = %+v with %d results, want no run and 2 results", run, results)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "results.jsonl")
+
+	if err := os.WriteFile(fileName, []byte(`{"Url":"http://localhost/"}`+"\nnot json\n"), 0o644); err != nil {
+		t.Fatalf("unable to write %s: %v", fileName, err)
+	}
+
+	if _, err := Read(fileName, func(result tester.MeasurementResult) {}); err == nil {
+		t.Errorf("Read() of an invalid line did not return an error")
+	}
+}
@@ -0,0 +1,66 @@
+package rawlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"os"
+)
+
+const maxLineSize = 1 << 20
+
+// Read restores the measurement results from the raw result log and passes them to consume in the order of the log.
+// It returns the settings of the run, they are empty for a log written without them.
+func Read(fileName string, consume func(result tester.MeasurementResult)) (Run, error) {
+	file, err := os.Open(fileName)
+
+	if err != nil {
+		return Run{}, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	line := 0
+	run := Run{}
+
+	for scanner.Scan() {
+		line++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		if line == 1 {
+			var first header
+
+			if err := json.Unmarshal(scanner.Bytes(), &first); err != nil {
+				return run, fmt.Errorf("%s:%d: %v", fileName, line, err)
+			}
+
+			if first.Run != nil {
+				run = *first.Run
+				continue
+			}
+		}
+
+		var record tester.ResultRecord
+
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return run, fmt.Errorf("%s:%d: %v", fileName, line, err)
+		}
+
+		result, err := record.MeasurementResult()
+
+		if err != nil {
+			return run, fmt.Errorf("%s:%d: %v", fileName, line, err)
+		}
+
+		consume(result)
+	}
+
+	return run, scanner.Err()
+}
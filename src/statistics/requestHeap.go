@@ -0,0 +1,93 @@
+package statistics
+
+import (
+	"container/heap"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"slices"
+	"time"
+)
+
+// requestHeap keeps the limit slowest (or fastest) requests seen so far. The root of the heap
+// is the request to be evicted first, so adding a request costs O(log limit).
+type requestHeap struct {
+	limit   int
+	slowest bool
+	results []tester.MeasurementResult
+}
+
+func newRequestHeap(limit int, slowest bool) *requestHeap {
+	return &requestHeap{
+		limit:   limit,
+		slowest: slowest,
+		results: make([]tester.MeasurementResult, 0, limit),
+	}
+}
+
+func (requests *requestHeap) add(result tester.MeasurementResult) {
+	if requests.Len() < requests.limit {
+		heap.Push(requests, result)
+		return
+	}
+
+	if requests.limit > 0 && requests.before(requests.results[0], result) {
+		requests.results[0] = result
+		heap.Fix(requests, 0)
+	}
+}
+
+// sorted returns the requests, the slowest (or fastest) one first
+func (requests *requestHeap) sorted() []tester.MeasurementResult {
+	results := slices.Clone(requests.results)
+
+	slices.SortStableFunc(
+		results, func(a, b tester.MeasurementResult) int {
+			if requests.before(b, a) {
+				return -1
+			}
+
+			if requests.before(a, b) {
+				return 1
+			}
+
+			return 0
+		},
+	)
+
+	return results
+}
+
+// before reports whether a is evicted before b
+func (requests *requestHeap) before(a, b tester.MeasurementResult) bool {
+	if requests.slowest {
+		return requestTime(a) < requestTime(b)
+	}
+
+	return requestTime(a) > requestTime(b)
+}
+
+func requestTime(result tester.MeasurementResult) time.Duration {
+	return result.RequestResult.Durations.Total.Total
+}
+
+func (requests *requestHeap) Len() int {
+	return len(requests.results)
+}
+
+func (requests *requestHeap) Less(i, j int) bool {
+	return requests.before(requests.results[i], requests.results[j])
+}
+
+func (requests *requestHeap) Swap(i, j int) {
+	requests.results[i], requests.results[j] = requests.results[j], requests.results[i]
+}
+
+func (requests *requestHeap) Push(value any) {
+	requests.results = append(requests.results, value.(tester.MeasurementResult))
+}
+
+func (requests *requestHeap) Pop() any {
+	last := requests.results[len(requests.results)-1]
+	requests.results = requests.results[:len(requests.results)-1]
+
+	return last
+}
@@ -1,18 +1,124 @@
 package statistics
 
 import (
+	"cmp"
+	"github.com/vpominchuk/wmetrics/src/histogram"
 	"github.com/vpominchuk/wmetrics/src/tester"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
-type QuantileResult struct {
-	Segment int
-	Min     time.Duration
-	Max     time.Duration
+const (
+	PhaseDNSLookup             = "dns"
+	PhaseTCPConnection         = "tcp"
+	PhaseTLSHandshake          = "tls"
+	PhaseConnectionEstablished = "connection"
+	PhaseTTFB                  = "ttfb"
+	PhaseContentTransfer       = "transfer"
+	PhaseTotal                 = "total"
+	PhaseCorrectedTotal        = "total_corrected"
+)
+
+// Phases lists the timing phases in the order of a request lifecycle
+var Phases = []string{
+	PhaseDNSLookup,
+	PhaseTCPConnection,
+	PhaseTLSHandshake,
+	PhaseConnectionEstablished,
+	PhaseTTFB,
+	PhaseContentTransfer,
+	PhaseTotal,
+	PhaseCorrectedTotal,
+}
+
+var DefaultPercentiles = []float64{50, 90, 95, 99, 99.9}
+
+// AllUrls is the key of the statistics aggregated over all URLs, they are calculated if more than one URL is tested
+const AllUrls = "all"
+
+const (
+	SortByMedian = "median"
+	SortByP95    = "p95"
+)
+
+// Percentiles maps a phase (dns, tcp, ..., total) to its percentiles keyed by name (p50, p99.9, ...)
+type Percentiles map[string]map[string]time.Duration
+
+type StageStatistics struct {
+	Stage    int
+	From, To int
+	Duration time.Duration
+
+	SingleUrlStatistics
+}
+
+// TimelineBucket summarizes the requests completed within one interval of the test
+type TimelineBucket struct {
+	// Offset is the start of the interval relative to the start of the test
+	Offset,
+	Duration time.Duration
+	RequestsPerSecond float64
+
+	TotalRequests,
+	ErrorRequests,
+	Code1xx,
+	Code2xx,
+	Code3xx,
+	Code4xx,
+	Code5xx,
+	OtherCodes,
+	MissedDispatches int
+
+	RequestTimeAvg,
+	RequestTimeMedian,
+	RequestTimeMax time.Duration
+
+	// Percentiles of the time per request keyed by name (p50, p99.9, ...)
+	Percentiles map[string]time.Duration
+}
+
+type WarmupStatistics struct {
+	TotalRequests,
+	ErrorRequests int
+	RequestTimeAvg,
+	RequestTimeMax time.Duration
+}
+
+// AssertionResult counts the responses which passed and failed the assertion
+type AssertionResult struct {
+	Assertion string
+	Passed,
+	Failed int
+}
+
+// ThresholdResult is the outcome of a threshold (-th), Actual is the measured value in Unit (ms, %, ...).
+// NoSamples is set if there was nothing to measure, e.g. p95 when every request failed, the threshold fails then.
+type ThresholdResult struct {
+	Threshold string
+	Actual    float64
+	Unit      string
+	NoSamples bool `json:",omitempty"`
+	Passed    bool
+}
+
+// RequestSample is one of the slowest or fastest requests with its connection phases,
+// TraceId and SpanId identify the request in the traces of the backend
+type RequestSample struct {
+	StartedAt  time.Time
+	StatusCode int
+	ErrorClass string `json:",omitempty"`
+	RemoteAddr string
+	Reused     bool
+	TraceId    string `json:",omitempty"`
+	SpanId     string `json:",omitempty"`
+	Durations  tester.Durations
 }
 
+// ErrorResult counts the failed requests of one error class (tester.ErrorClassDNS, ...), Message is a sample error
 type ErrorResult struct {
+	Class   string
 	Message string
 	Count   int
 }
@@ -21,7 +127,14 @@ type SingleUrlStatistics struct {
 	RequestTimeAvg,
 	RequestTimeMin,
 	RequestTimeMax,
-	RequestTimeMedian,
+	RequestTimeMedian time.Duration
+
+	// CorrectedRequestTime is measured from the scheduled start of each request, see CoordinatedOmissionCorrection.
+	// It is only set in the open model (-rate).
+	CorrectedRequestTimeAvg,
+	CorrectedRequestTimeMin,
+	CorrectedRequestTimeMax,
+	CorrectedRequestTimeMedian time.Duration `json:",omitempty"`
 
 	TotalTime,
 
@@ -48,41 +161,256 @@ type SingleUrlStatistics struct {
 	TTFBAvg,
 	TTFBMin,
 	TTFBMax,
-	TTFBMedian time.Duration
+	TTFBMedian,
 
-	TotalTimePercentage []QuantileResult
+	ContentTransferAvg,
+	ContentTransferMin,
+	ContentTransferMax,
+	ContentTransferMedian time.Duration
+
+	// BytesReceived includes headers and bodies as received over the wire
+	BytesReceived,
+	ResponseSizeAvg,
+	DecodedResponseSizeAvg int64
+	ThroughputMBps float64
+
+	Percentiles Percentiles
+
+	// Histograms holds the non-empty histogram buckets of every phase in nanoseconds, if Options.Histograms is set.
+	// Histograms of different runs can be merged with histogram.FromBuckets of their joined buckets.
+	Histograms map[string][]histogram.Bucket `json:",omitempty"`
+
+	// CoordinatedOmissionCorrection is set if the total_corrected percentiles are measured
+	// from the scheduled start of each request (-rate). Missed dispatches are included
+	// as if they completed at the end of the test.
+	CoordinatedOmissionCorrection bool
 
 	ErrorRequests,
 	SuccessRequests,
 	TotalRequests,
+	Code1xx,
 	Code2xx,
 	Code3xx,
 	Code4xx,
 	Code5xx,
-	OtherCodes int
+	OtherCodes,
+	ReusedConnections,
+	IdleConnections,
+	MissedDispatches,
+	LateDispatches int
 
 	Server, PoweredBy string
 
+	// Interrupted is set if the test has been stopped before completion, the statistics are partial
+	Interrupted bool
+
 	Errors []ErrorResult
+
+	Assertions []AssertionResult
+
+	Thresholds []ThresholdResult
+
+	// SlowestRequests and FastestRequests list the requests with the longest and the shortest time,
+	// the slowest (or fastest) one first
+	SlowestRequests []RequestSample `json:",omitempty"`
+	FastestRequests []RequestSample `json:",omitempty"`
+
+	Stages []StageStatistics
+
+	// Timeline splits the test into intervals by the completion time of the requests
+	Timeline []TimelineBucket
+
+	// Warmup summarizes the requests which are excluded from the statistics above
+	Warmup WarmupStatistics
 }
 
 type Statistics map[string]SingleUrlStatistics
 
+// durationAccumulator keeps the durations in a histogram, so the memory usage does not grow with the number of requests
+type durationAccumulator struct {
+	histogram *histogram.Histogram
+}
+
+// statusCodes counts the responses by the class of their status code
+type statusCodes struct {
+	code1xx,
+	code2xx,
+	code3xx,
+	code4xx,
+	code5xx,
+	otherCodes int
+}
+
+type urlAccumulator struct {
+	statusCodes
+
+	errorRequests,
+	successRequests,
+	totalRequests,
+	reusedConnections,
+	idleConnections,
+	missedDispatches,
+	lateDispatches int
+
+	requestTime, correctedRequestTime, dnsLookup, tcpConnection, tlsHandshake, connectionEstablished,
+	ttfb, contentTransfer durationAccumulator
+
+	// missedSchedule keeps the scheduled starts of the missed dispatches relative to the start of the run
+	missedSchedule durationAccumulator
+
+	headerBytes, bodyBytes, decodedBytes int64
+
+	scheduled bool
+
+	errors            map[string]*ErrorResult
+	assertionsChecked int
+	failedAssertions  map[string]int
+	server, poweredBy string
+
+	stages   map[int]*urlAccumulator
+	timeline []*timelineAccumulator
+	warmup   *urlAccumulator
+
+	// the requests below are only kept by the accumulators of the whole test
+	slowestRequests,
+	fastestRequests *requestHeap
+}
+
+// timelineAccumulator keeps only what a timeline bucket shows, as the number of buckets grows with the test duration
+type timelineAccumulator struct {
+	statusCodes
+
+	totalRequests,
+	errorRequests,
+	missedDispatches int
+
+	requestTime durationAccumulator
+}
+
+// Collector aggregates measurement results as they arrive, so the results
+// themselves do not have to be kept in memory until the end of the test.
+type Collector struct {
+	options Options
+	urls    map[string]*urlAccumulator
+	all     *urlAccumulator
+	start   time.Time
+}
+
+type Options struct {
+	Stages      []tester.Stage
+	Percentiles []float64
+
+	// Assertions lists the names of the declared assertions, in the order of the report
+	Assertions []string
+
+	// TimelineInterval enables the timeline with buckets of the given size
+	TimelineInterval time.Duration
+
+	// Histograms exports the histogram buckets of every phase
+	Histograms bool
+
+	// SlowestRequests and FastestRequests are the numbers of the requests to list with their connection phases
+	SlowestRequests,
+	FastestRequests int
+}
+
+func NewCollector(options Options) *Collector {
+	if len(options.Percentiles) == 0 {
+		options.Percentiles = DefaultPercentiles
+	}
+
+	collector := &Collector{
+		options: options,
+		urls:    make(map[string]*urlAccumulator),
+		all:     newUrlAccumulator(tester.MeasurementResult{}),
+	}
+
+	collector.trackRequests(collector.all)
+
+	return collector
+}
+
+func (collector *Collector) trackRequests(accumulator *urlAccumulator) {
+	if collector.options.SlowestRequests > 0 {
+		accumulator.slowestRequests = newRequestHeap(collector.options.SlowestRequests, true)
+	}
+
+	if collector.options.FastestRequests > 0 {
+		accumulator.fastestRequests = newRequestHeap(collector.options.FastestRequests, false)
+	}
+}
+
 func GetStatistics(results []tester.MeasurementResult, testDuration time.Duration) (Statistics, error) {
-	var urls = make(map[string][]tester.MeasurementResult)
+	collector := NewCollector(Options{})
 
 	for _, result := range results {
-		if result.RequestResult.Resource.Url != nil {
-			url := result.RequestResult.Resource.Url.String()
+		collector.Consume(result)
+	}
 
-			urls[url] = append(urls[url], result)
-		}
+	return collector.GetStatistics(testDuration)
+}
+
+func (collector *Collector) Consume(result tester.MeasurementResult) {
+	if result.RequestResult.Resource.Url == nil {
+		return
+	}
+
+	url := result.RequestResult.Resource.Url.String()
+	start := collector.runStart(result)
+
+	accumulator, ok := collector.urls[url]
+
+	if !ok {
+		accumulator = newUrlAccumulator(result)
+		collector.trackRequests(accumulator)
+		collector.urls[url] = accumulator
+	}
+
+	timelineBucket := collector.timelineBucket(result)
+
+	accumulator.add(result, timelineBucket, start)
+	collector.all.add(result, timelineBucket, start)
+}
+
+// RunStarted sets the start of the timeline to the start of the measured run, after the warm-up
+func (collector *Collector) RunStarted(start time.Time) {
+	collector.start = start
+}
+
+// runStart returns the start of the measured run, or the start of the first request if the run start is unknown
+func (collector *Collector) runStart(result tester.MeasurementResult) time.Time {
+	if collector.start.IsZero() && !result.RequestResult.Dispatch.Warmup {
+		collector.start = result.StartedAt()
 	}
 
+	return collector.start
+}
+
+// timelineBucket returns the index of the timeline bucket the result belongs to, or -1 if there is no timeline.
+// The timeline starts with the measured run. Warm-up requests are not included.
+func (collector *Collector) timelineBucket(result tester.MeasurementResult) int {
+	if collector.options.TimelineInterval <= 0 || result.RequestResult.Dispatch.Warmup {
+		return -1
+	}
+
+	return max(int(result.CompletedAt().Sub(collector.start)/collector.options.TimelineInterval), 0)
+}
+
+func (collector *Collector) TotalRequests() int {
+	totalRequests := 0
+
+	for _, accumulator := range collector.urls {
+		totalRequests += accumulator.totalRequests
+	}
+
+	return totalRequests
+}
+
+func (collector *Collector) GetStatistics(testDuration time.Duration) (Statistics, error) {
 	statistics := make(Statistics)
 
-	for url, urlResults := range urls {
-		stat, err := calculateStatistics(urlResults, testDuration)
+	for url, accumulator := range collector.urls {
+		stat, err := collector.calculateUrlStatistics(accumulator, testDuration)
 
 		if err != nil {
 			return nil, err
@@ -91,231 +419,670 @@ func GetStatistics(results []tester.MeasurementResult, testDuration time.Duratio
 		statistics[url] = stat
 	}
 
+	if len(collector.urls) > 1 {
+		stat, err := collector.calculateUrlStatistics(collector.all, testDuration)
+
+		if err != nil {
+			return nil, err
+		}
+
+		statistics[AllUrls] = stat
+	}
+
 	return statistics, nil
 }
 
-func calculateStatistics(results []tester.MeasurementResult, testDuration time.Duration) (SingleUrlStatistics, error) {
-	var errorRequests, successRequests, totalRequests, code2xx, code3xx, code4xx, code5xx, otherCodes int
-	var connectionEstablishedAvg, connectionEstablishedMin, connectionEstablishedMax time.Duration
-	var tcpConnectionAvg, tcpConnectionMin, tcpConnectionMax time.Duration
-	var tlsHandshakeAvg, tlsHandshakeMin, tlsHandshakeMax time.Duration
-	var requestTimeAvg, requestTimeMin, requestTimeMax time.Duration
-	var dnsLookupAvg, dnsLookupMin, dnsLookupMax time.Duration
-	var ttfbAvg, ttfbMin, ttfbMax time.Duration
+func (collector *Collector) calculateUrlStatistics(
+	accumulator *urlAccumulator, testDuration time.Duration,
+) (SingleUrlStatistics, error) {
+	stat, err := accumulator.calculateStatistics(testDuration, testDuration, collector.options)
+
+	if err != nil {
+		return stat, err
+	}
+
+	stat.Stages, err = collector.calculateStageStatistics(accumulator, testDuration)
+
+	if err != nil {
+		return stat, err
+	}
+
+	stat.Timeline = collector.calculateTimeline(accumulator, testDuration)
+	stat.Warmup = accumulator.calculateWarmupStatistics()
+	stat.SlowestRequests = calculateRequestSamples(accumulator.slowestRequests)
+	stat.FastestRequests = calculateRequestSamples(accumulator.fastestRequests)
+
+	return stat, nil
+}
+
+// Urls returns the tested URLs in alphabetical order, the statistics of all URLs are not included
+func (statistics Statistics) Urls() []string {
+	urls := make([]string, 0, len(statistics))
+
+	for url := range statistics {
+		if url != AllUrls {
+			urls = append(urls, url)
+		}
+	}
+
+	slices.Sort(urls)
+
+	return urls
+}
+
+// UrlsSortedBy returns the tested URLs ordered by the time per request (SortByMedian, SortByP95), fastest first
+func (statistics Statistics) UrlsSortedBy(sortBy string) []string {
+	urls := statistics.Urls()
 
-	errors := make(map[string]int)
+	slices.SortStableFunc(
+		urls, func(a, b string) int {
+			return cmp.Compare(statistics[a].sortValue(sortBy), statistics[b].sortValue(sortBy))
+		},
+	)
 
-	var timingPool struct {
-		totalTime, dnsLookup, tcpConnection, tlsHandshake, connectionEstablished, ttfb []time.Duration
+	return urls
+}
+
+func (stat SingleUrlStatistics) sortValue(sortBy string) time.Duration {
+	if sortBy == SortByP95 {
+		return stat.Percentiles[PhaseTotal]["p95"]
 	}
 
-	for _, result := range results {
-		if result.Error == nil && result.RequestResult.Error == nil {
-			requestTimeAvg += result.RequestResult.Durations.Total.Total
-			requestTimeMin = minDuration(requestTimeMin, result.RequestResult.Durations.Total.Total)
-			requestTimeMax = maxDuration(requestTimeMax, result.RequestResult.Durations.Total.Total)
-			timingPool.totalTime = append(timingPool.totalTime, result.RequestResult.Durations.Total.Total)
-
-			tcpConnectionAvg += result.RequestResult.Durations.TCPConnection.Duration
-			tcpConnectionMax = maxDuration(tcpConnectionMax, result.RequestResult.Durations.TCPConnection.Duration)
-			tcpConnectionMin = minDuration(tcpConnectionMin, result.RequestResult.Durations.TCPConnection.Duration)
-			timingPool.tcpConnection = append(
-				timingPool.tcpConnection, result.RequestResult.Durations.TCPConnection.Duration,
-			)
-
-			tlsHandshakeAvg += result.RequestResult.Durations.TLSHandshake.Duration
-			tlsHandshakeMin = minDuration(tlsHandshakeMin, result.RequestResult.Durations.TLSHandshake.Duration)
-			tlsHandshakeMax = maxDuration(tlsHandshakeMax, result.RequestResult.Durations.TLSHandshake.Duration)
-			timingPool.tlsHandshake = append(
-				timingPool.tlsHandshake, result.RequestResult.Durations.TLSHandshake.Duration,
-			)
-
-			connectionEstablishedAvg += result.RequestResult.Durations.ConnectionEstablishment.Duration
-			connectionEstablishedMin = minDuration(
-				connectionEstablishedMin, result.RequestResult.Durations.ConnectionEstablishment.Duration,
-			)
-			connectionEstablishedMax = maxDuration(
-				connectionEstablishedMax, result.RequestResult.Durations.ConnectionEstablishment.Duration,
-			)
-			timingPool.connectionEstablished = append(
-				timingPool.connectionEstablished, result.RequestResult.Durations.ConnectionEstablishment.Duration,
-			)
-
-			ttfbAvg += result.RequestResult.Durations.TTFB.Duration
-			ttfbMin = minDuration(ttfbMin, result.RequestResult.Durations.TTFB.Duration)
-			ttfbMax = maxDuration(ttfbMax, result.RequestResult.Durations.TTFB.Duration)
-			timingPool.ttfb = append(timingPool.ttfb, result.RequestResult.Durations.TTFB.Duration)
-
-			successRequests++
-
-			if result.RequestResult.StatusCode >= 200 && result.RequestResult.StatusCode < 300 {
-				code2xx++
-			} else if result.RequestResult.StatusCode >= 300 && result.RequestResult.StatusCode < 400 {
-				code3xx++
-			} else if result.RequestResult.StatusCode >= 400 && result.RequestResult.StatusCode < 500 {
-				code4xx++
-			} else if result.RequestResult.StatusCode >= 500 && result.RequestResult.StatusCode < 600 {
-				code5xx++
-			} else {
-				otherCodes++
-			}
-		} else {
-			errorRequests++
+	return stat.RequestTimeMedian
+}
+
+func (statistics Statistics) MarkInterrupted() {
+	for url, stat := range statistics {
+		stat.Interrupted = true
+		statistics[url] = stat
+	}
+}
+
+func (collector *Collector) calculateStageStatistics(
+	accumulator *urlAccumulator, runDuration time.Duration,
+) ([]StageStatistics, error) {
+	if len(collector.options.Stages) == 0 {
+		return nil, nil
+	}
+
+	stages := make([]StageStatistics, 0, len(collector.options.Stages))
+	from := 0
+
+	for index, stage := range collector.options.Stages {
+		stageAccumulator, ok := accumulator.stages[index+1]
+
+		if !ok {
+			stageAccumulator = newUrlAccumulator(tester.MeasurementResult{})
+		}
+
+		stat, err := stageAccumulator.calculateStatistics(stage.Duration, runDuration, collector.options)
+
+		if err != nil {
+			return nil, err
+		}
+
+		stages = append(
+			stages, StageStatistics{
+				Stage:               index + 1,
+				From:                from,
+				To:                  stage.Target,
+				Duration:            stage.Duration,
+				SingleUrlStatistics: stat,
+			},
+		)
+
+		from = stage.Target
+	}
+
+	return stages, nil
+}
+
+func (collector *Collector) calculateTimeline(accumulator *urlAccumulator, testDuration time.Duration) []TimelineBucket {
+	if len(accumulator.timeline) == 0 {
+		return nil
+	}
+
+	interval := collector.options.TimelineInterval
+	timeline := make([]TimelineBucket, 0, len(accumulator.timeline))
+
+	for index, bucketAccumulator := range accumulator.timeline {
+		if bucketAccumulator == nil {
+			bucketAccumulator = &timelineAccumulator{}
+		}
+
+		offset := time.Duration(index) * interval
+		duration := interval
+
+		// the last bucket may be shorter than the interval
+		if testDuration > offset && testDuration-offset < interval {
+			duration = testDuration - offset
+		}
+
+		bucket := TimelineBucket{
+			Offset:            offset,
+			Duration:          duration,
+			RequestsPerSecond: float64(bucketAccumulator.totalRequests) / duration.Seconds(),
+			TotalRequests:     bucketAccumulator.totalRequests,
+			ErrorRequests:     bucketAccumulator.errorRequests,
+			Code1xx:           bucketAccumulator.code1xx,
+			Code2xx:           bucketAccumulator.code2xx,
+			Code3xx:           bucketAccumulator.code3xx,
+			Code4xx:           bucketAccumulator.code4xx,
+			Code5xx:           bucketAccumulator.code5xx,
+			OtherCodes:        bucketAccumulator.otherCodes,
+			MissedDispatches:  bucketAccumulator.missedDispatches,
+			RequestTimeAvg:    bucketAccumulator.requestTime.avg(),
+			RequestTimeMedian: bucketAccumulator.requestTime.median(),
+			RequestTimeMax:    bucketAccumulator.requestTime.max(),
+			Percentiles:       make(map[string]time.Duration, len(collector.options.Percentiles)),
+		}
+
+		for _, percentile := range collector.options.Percentiles {
+			bucket.Percentiles[PercentileKey(percentile)] = bucketAccumulator.requestTime.percentile(percentile)
+		}
+
+		timeline = append(timeline, bucket)
+	}
+
+	return timeline
+}
+
+func newUrlAccumulator(result tester.MeasurementResult) *urlAccumulator {
+	return &urlAccumulator{
+		errors:           make(map[string]*ErrorResult),
+		failedAssertions: make(map[string]int),
+		server:           result.RequestResult.Headers.Server,
+		poweredBy:        result.RequestResult.Headers.PoweredBy,
+		stages:           make(map[int]*urlAccumulator),
+	}
+}
+
+func (accumulator *urlAccumulator) add(result tester.MeasurementResult, timelineBucket int, start time.Time) {
+	if result.RequestResult.Dispatch.Warmup {
+		if accumulator.warmup == nil {
+			accumulator.warmup = newUrlAccumulator(result)
+		}
+
+		accumulator.warmup.record(result, start)
+
+		return
+	}
+
+	accumulator.record(result, start)
+
+	if !result.RequestResult.Dispatch.Missed {
+		accumulator.addSample(result)
+	}
+
+	stage := result.RequestResult.Dispatch.Stage
+
+	if stage > 0 {
+		stageAccumulator, ok := accumulator.stages[stage]
+
+		if !ok {
+			stageAccumulator = newUrlAccumulator(result)
+			accumulator.stages[stage] = stageAccumulator
 		}
 
-		dnsLookupAvg += result.RequestResult.Durations.DNSLookup.Duration
-		dnsLookupMin = minDuration(dnsLookupMin, result.RequestResult.Durations.DNSLookup.Duration)
-		dnsLookupMax = maxDuration(dnsLookupMax, result.RequestResult.Durations.DNSLookup.Duration)
-		timingPool.dnsLookup = append(timingPool.dnsLookup, result.RequestResult.Durations.DNSLookup.Duration)
+		stageAccumulator.record(result, start)
+	}
 
-		if result.Error != nil {
-			errors[result.Error.Error()]++
+	if timelineBucket >= 0 {
+		for len(accumulator.timeline) <= timelineBucket {
+			accumulator.timeline = append(accumulator.timeline, nil)
 		}
 
-		if result.RequestResult.Error != nil {
-			errors[result.RequestResult.Error.Error()]++
+		if accumulator.timeline[timelineBucket] == nil {
+			accumulator.timeline[timelineBucket] = &timelineAccumulator{}
 		}
 
-		totalRequests++
+		accumulator.timeline[timelineBucket].record(result)
 	}
+}
+
+// record adds the result to the accumulator, start is the start of the run the result belongs to
+func (accumulator *urlAccumulator) record(result tester.MeasurementResult, start time.Time) {
+	dispatch := result.RequestResult.Dispatch
+
+	if !dispatch.Scheduled.IsZero() {
+		accumulator.scheduled = true
+	}
+
+	if dispatch.Missed {
+		accumulator.missedDispatches++
+		accumulator.missedSchedule.add(dispatch.Scheduled.Sub(start))
 
-	errorResult := make([]ErrorResult, 0, len(errors))
+		return
+	}
+
+	if dispatch.Late {
+		accumulator.lateDispatches++
+	}
+
+	durations := result.RequestResult.Durations
+
+	if result.Error == nil && result.RequestResult.Error == nil {
+		accumulator.requestTime.add(durations.Total.Total)
+		accumulator.correctedRequestTime.add(result.RequestResult.CorrectedTotal())
+		accumulator.tcpConnection.add(durations.TCPConnection.Duration)
+		accumulator.tlsHandshake.add(durations.TLSHandshake.Duration)
+		accumulator.connectionEstablished.add(durations.ConnectionEstablishment.Duration)
+		accumulator.ttfb.add(durations.TTFB.Duration)
+		accumulator.contentTransfer.add(durations.ContentTransfer.Duration)
+
+		accumulator.successRequests++
+		accumulator.statusCodes.add(result.RequestResult.StatusCode)
+	} else {
+		accumulator.errorRequests++
+	}
+
+	accumulator.dnsLookup.add(durations.DNSLookup.Duration)
+
+	accumulator.headerBytes += result.RequestResult.BytesReceived.Headers
+	accumulator.bodyBytes += result.RequestResult.BytesReceived.Body
+	accumulator.decodedBytes += result.RequestResult.BytesReceived.Decoded
+
+	if result.RequestResult.Connection.Reused {
+		accumulator.reusedConnections++
+	}
+
+	if result.RequestResult.Connection.WasIdle {
+		accumulator.idleConnections++
+	}
+
+	if result.RequestResult.AssertionsChecked {
+		accumulator.assertionsChecked++
+
+		for _, assertion := range result.RequestResult.FailedAssertions {
+			accumulator.failedAssertions[assertion]++
+		}
+	}
+
+	if err := result.PrimaryError(); err != nil {
+		class := tester.ClassifyError(err)
+		errorResult, ok := accumulator.errors[class]
+
+		if !ok {
+			errorResult = &ErrorResult{Class: class, Message: err.Error()}
+			accumulator.errors[class] = errorResult
+		}
+
+		errorResult.Count++
+	}
+
+	accumulator.totalRequests++
+}
+
+func (codes *statusCodes) add(statusCode int) {
+	if statusCode >= 100 && statusCode < 200 {
+		codes.code1xx++
+	} else if statusCode >= 200 && statusCode < 300 {
+		codes.code2xx++
+	} else if statusCode >= 300 && statusCode < 400 {
+		codes.code3xx++
+	} else if statusCode >= 400 && statusCode < 500 {
+		codes.code4xx++
+	} else if statusCode >= 500 && statusCode < 600 {
+		codes.code5xx++
+	} else {
+		codes.otherCodes++
+	}
+}
+
+func (bucket *timelineAccumulator) record(result tester.MeasurementResult) {
+	if result.RequestResult.Dispatch.Missed {
+		bucket.missedDispatches++
+		return
+	}
+
+	if result.PrimaryError() == nil {
+		bucket.requestTime.add(result.RequestResult.Durations.Total.Total)
+		bucket.statusCodes.add(result.RequestResult.StatusCode)
+	} else {
+		bucket.errorRequests++
+	}
+
+	bucket.totalRequests++
+}
+
+// addSample offers the request to the slowest and fastest requests. Failed requests are not
+// among the fastest ones, as a refused connection would hide the fastest responses.
+func (accumulator *urlAccumulator) addSample(result tester.MeasurementResult) {
+	if accumulator.slowestRequests != nil {
+		accumulator.slowestRequests.add(result)
+	}
+
+	if accumulator.fastestRequests != nil && result.PrimaryError() == nil {
+		accumulator.fastestRequests.add(result)
+	}
+}
+
+func calculateRequestSamples(requests *requestHeap) []RequestSample {
+	if requests == nil {
+		return nil
+	}
 
-	for err, count := range errors {
-		errorResult = append(
-			errorResult, ErrorResult{
-				Message: err,
-				Count:   count,
+	results := make([]RequestSample, 0, requests.Len())
+
+	for _, result := range requests.sorted() {
+		results = append(
+			results, RequestSample{
+				StartedAt:  result.StartedAt(),
+				StatusCode: result.RequestResult.StatusCode,
+				ErrorClass: tester.ClassifyError(result.PrimaryError()),
+				RemoteAddr: result.RequestResult.Connection.RemoteAddr,
+				Reused:     result.RequestResult.Connection.Reused,
+				TraceId:    result.RequestResult.Trace.TraceId,
+				SpanId:     result.RequestResult.Trace.SpanId,
+				Durations:  result.RequestResult.Durations,
 			},
 		)
 	}
 
-	server, poweredBy := "", ""
+	return results
+}
+
+func (accumulator *urlAccumulator) calculateAssertions(assertions []string) []AssertionResult {
+	if len(assertions) == 0 {
+		return nil
+	}
+
+	results := make([]AssertionResult, 0, len(assertions))
 
-	if len(results) > 0 {
-		server = results[0].RequestResult.Headers.Server
-		poweredBy = results[0].RequestResult.Headers.PoweredBy
+	for _, assertion := range assertions {
+		results = append(
+			results, AssertionResult{
+				Assertion: assertion,
+				Passed:    accumulator.assertionsChecked - accumulator.failedAssertions[assertion],
+				Failed:    accumulator.failedAssertions[assertion],
+			},
+		)
 	}
 
-	return SingleUrlStatistics{
-		Server:              server,
-		PoweredBy:           poweredBy,
-		RequestTimeAvg:      requestTimeAvg / time.Duration(len(results)),
-		RequestTimeMin:      requestTimeMin,
-		RequestTimeMax:      requestTimeMax,
-		RequestTimeMedian:   calculateDurationMedian(timingPool.totalTime),
-		TotalTimePercentage: splitDataIntoSegments(timingPool.totalTime, 10),
+	return results
+}
+
+func (accumulator *urlAccumulator) calculateWarmupStatistics() WarmupStatistics {
+	if accumulator.warmup == nil {
+		return WarmupStatistics{}
+	}
 
-		DNSLookupAvg:    dnsLookupAvg / time.Duration(len(results)),
-		DNSLookupMin:    dnsLookupMin,
-		DNSLookupMax:    dnsLookupMax,
-		DNSLookupMedian: calculateDurationMedian(timingPool.dnsLookup),
+	return WarmupStatistics{
+		TotalRequests:  accumulator.warmup.totalRequests,
+		ErrorRequests:  accumulator.warmup.errorRequests,
+		RequestTimeAvg: accumulator.warmup.requestTime.avg(),
+		RequestTimeMax: accumulator.warmup.requestTime.max(),
+	}
+}
 
-		TCPConnectionAvg:    tcpConnectionAvg / time.Duration(len(results)),
-		TCPConnectionMin:    tcpConnectionMin,
-		TCPConnectionMax:    tcpConnectionMax,
-		TCPConnectionMedian: calculateDurationMedian(timingPool.tcpConnection),
+// calculateStatistics returns the statistics of the accumulator over testDuration, e.g. of a stage,
+// runDuration is the duration of the whole run the missed dispatches are corrected by
+func (accumulator *urlAccumulator) calculateStatistics(
+	testDuration, runDuration time.Duration, options Options,
+) (SingleUrlStatistics, error) {
+	errorResult := make([]ErrorResult, 0, len(accumulator.errors))
 
-		TLSHandshakeAvg:    tlsHandshakeAvg / time.Duration(len(results)),
-		TLSHandshakeMin:    tlsHandshakeMin,
-		TLSHandshakeMax:    tlsHandshakeMax,
-		TLSHandshakeMedian: calculateDurationMedian(timingPool.tlsHandshake),
+	for _, result := range accumulator.errors {
+		errorResult = append(errorResult, *result)
+	}
 
-		ConnectionEstablishedAvg:    connectionEstablishedAvg / time.Duration(len(results)),
-		ConnectionEstablishedMin:    connectionEstablishedMin,
-		ConnectionEstablishedMax:    connectionEstablishedMax,
-		ConnectionEstablishedMedian: calculateDurationMedian(timingPool.connectionEstablished),
+	slices.SortFunc(
+		errorResult, func(a, b ErrorResult) int {
+			if a.Count != b.Count {
+				return cmp.Compare(b.Count, a.Count)
+			}
 
-		TTFBAvg:    ttfbAvg / time.Duration(len(results)),
-		TTFBMin:    ttfbMin,
-		TTFBMax:    ttfbMax,
-		TTFBMedian: calculateDurationMedian(timingPool.ttfb),
+			return cmp.Compare(a.Class, b.Class)
+		},
+	)
+
+	phases := accumulator.phases(runDuration)
+
+	stat := SingleUrlStatistics{
+		Server:            accumulator.server,
+		PoweredBy:         accumulator.poweredBy,
+		RequestTimeAvg:    accumulator.requestTime.avg(),
+		RequestTimeMin:    accumulator.requestTime.min(),
+		RequestTimeMax:    accumulator.requestTime.max(),
+		RequestTimeMedian: accumulator.requestTime.median(),
+
+		Percentiles:                   calculatePercentiles(phases, options.Percentiles),
+		Histograms:                    exportHistograms(phases, options.Histograms),
+		CoordinatedOmissionCorrection: accumulator.scheduled,
+
+		DNSLookupAvg:    accumulator.dnsLookup.avg(),
+		DNSLookupMin:    accumulator.dnsLookup.min(),
+		DNSLookupMax:    accumulator.dnsLookup.max(),
+		DNSLookupMedian: accumulator.dnsLookup.median(),
+
+		TCPConnectionAvg:    accumulator.tcpConnection.avg(),
+		TCPConnectionMin:    accumulator.tcpConnection.min(),
+		TCPConnectionMax:    accumulator.tcpConnection.max(),
+		TCPConnectionMedian: accumulator.tcpConnection.median(),
+
+		TLSHandshakeAvg:    accumulator.tlsHandshake.avg(),
+		TLSHandshakeMin:    accumulator.tlsHandshake.min(),
+		TLSHandshakeMax:    accumulator.tlsHandshake.max(),
+		TLSHandshakeMedian: accumulator.tlsHandshake.median(),
+
+		ConnectionEstablishedAvg:    accumulator.connectionEstablished.avg(),
+		ConnectionEstablishedMin:    accumulator.connectionEstablished.min(),
+		ConnectionEstablishedMax:    accumulator.connectionEstablished.max(),
+		ConnectionEstablishedMedian: accumulator.connectionEstablished.median(),
+
+		TTFBAvg:    accumulator.ttfb.avg(),
+		TTFBMin:    accumulator.ttfb.min(),
+		TTFBMax:    accumulator.ttfb.max(),
+		TTFBMedian: accumulator.ttfb.median(),
+
+		ContentTransferAvg:    accumulator.contentTransfer.avg(),
+		ContentTransferMin:    accumulator.contentTransfer.min(),
+		ContentTransferMax:    accumulator.contentTransfer.max(),
+		ContentTransferMedian: accumulator.contentTransfer.median(),
+
+		BytesReceived:          accumulator.headerBytes + accumulator.bodyBytes,
+		ResponseSizeAvg:        averageSize(accumulator.bodyBytes, accumulator.totalRequests),
+		DecodedResponseSizeAvg: averageSize(accumulator.decodedBytes, accumulator.totalRequests),
+		ThroughputMBps:         calculateThroughput(accumulator.headerBytes+accumulator.bodyBytes, testDuration),
 
 		TotalTime:       testDuration,
-		ErrorRequests:   errorRequests,
-		SuccessRequests: successRequests,
-		TotalRequests:   totalRequests,
-		Code2xx:         code2xx,
-		Code3xx:         code3xx,
-		Code4xx:         code4xx,
-		Code5xx:         code5xx,
-		OtherCodes:      otherCodes,
+		ErrorRequests:   accumulator.errorRequests,
+		SuccessRequests: accumulator.successRequests,
+		TotalRequests:   accumulator.totalRequests,
+		Code1xx:         accumulator.code1xx,
+		Code2xx:         accumulator.code2xx,
+		Code3xx:         accumulator.code3xx,
+		Code4xx:         accumulator.code4xx,
+		Code5xx:         accumulator.code5xx,
+		OtherCodes:      accumulator.otherCodes,
+
+		ReusedConnections: accumulator.reusedConnections,
+		IdleConnections:   accumulator.idleConnections,
+		MissedDispatches:  accumulator.missedDispatches,
+		LateDispatches:    accumulator.lateDispatches,
+
+		Assertions: accumulator.calculateAssertions(options.Assertions),
 
 		Errors: errorResult,
-	}, nil
+	}
+
+	if corrected, ok := phases[PhaseCorrectedTotal]; ok {
+		stat.CorrectedRequestTimeAvg = corrected.avg()
+		stat.CorrectedRequestTimeMin = corrected.min()
+		stat.CorrectedRequestTimeMax = corrected.max()
+		stat.CorrectedRequestTimeMedian = corrected.median()
+	}
+
+	return stat, nil
 }
 
-func maxDuration(a, b time.Duration) time.Duration {
-	if a > b {
-		return a
+func (accumulator *durationAccumulator) add(duration time.Duration) {
+	if accumulator.histogram == nil {
+		accumulator.histogram = histogram.New()
 	}
 
-	return b
+	accumulator.histogram.Record(int64(duration))
 }
 
-func minDuration(a, b time.Duration) time.Duration {
-	if b < a || a == 0 {
-		return b
+func (accumulator *durationAccumulator) count() int64 {
+	if accumulator.histogram == nil {
+		return 0
 	}
 
-	return a
+	return accumulator.histogram.Count()
 }
 
-func calculateDurationMedian(data []time.Duration) time.Duration {
-	slices.Sort(data)
+func (accumulator *durationAccumulator) avg() time.Duration {
+	if accumulator.count() == 0 {
+		return 0
+	}
 
-	n := len(data)
+	return time.Duration(accumulator.histogram.Mean())
+}
 
-	if n == 0 {
+func (accumulator *durationAccumulator) min() time.Duration {
+	if accumulator.count() == 0 {
 		return 0
 	}
 
-	if n%2 == 1 {
-		return data[n/2]
+	return time.Duration(accumulator.histogram.Min())
+}
+
+func (accumulator *durationAccumulator) max() time.Duration {
+	if accumulator.count() == 0 {
+		return 0
 	}
 
-	return (data[n/2-1] + data[n/2]) / 2
+	return time.Duration(accumulator.histogram.Max())
 }
 
-func splitDataIntoSegments(data []time.Duration, numSegments int) []QuantileResult {
-	dataLength := len(data)
+func (accumulator *durationAccumulator) median() time.Duration {
+	return accumulator.percentile(50)
+}
 
-	if dataLength == 0 || numSegments <= 0 {
-		return nil
+func (accumulator *durationAccumulator) percentile(percentile float64) time.Duration {
+	if accumulator.count() == 0 {
+		return 0
 	}
 
-	slices.Sort(data)
+	return time.Duration(accumulator.histogram.ValueAtPercentile(percentile))
+}
 
-	segmentSize := dataLength / numSegments
-	segments := make([]QuantileResult, 0, numSegments)
+func averageSize(total int64, count int) int64 {
+	if count == 0 {
+		return 0
+	}
 
-	if dataLength < numSegments {
-		segments = append(
-			segments, QuantileResult{
-				Segment: numSegments,
-				Min:     data[0],
-				Max:     data[dataLength-1],
-			},
-		)
+	return total / int64(count)
+}
 
-		return segments
+func calculateThroughput(bytes int64, duration time.Duration) float64 {
+	if duration <= 0 {
+		return 0
 	}
 
-	for i := 0; i < numSegments; i++ {
-		startIndex := i * segmentSize
-		endIndex := (i + 1) * segmentSize
+	return float64(bytes) / 1e6 / duration.Seconds()
+}
 
-		minValue := data[startIndex]
-		maxValue := data[endIndex-1]
+// phases returns the accumulators of the timing phases, the corrected request time only in the open model
+func (accumulator *urlAccumulator) phases(runDuration time.Duration) map[string]*durationAccumulator {
+	phases := map[string]*durationAccumulator{
+		PhaseDNSLookup:             &accumulator.dnsLookup,
+		PhaseTCPConnection:         &accumulator.tcpConnection,
+		PhaseTLSHandshake:          &accumulator.tlsHandshake,
+		PhaseConnectionEstablished: &accumulator.connectionEstablished,
+		PhaseTTFB:                  &accumulator.ttfb,
+		PhaseContentTransfer:       &accumulator.contentTransfer,
+		PhaseTotal:                 &accumulator.requestTime,
+	}
 
-		segments = append(
-			segments, QuantileResult{
-				Segment: i + 1,
-				Min:     minValue,
-				Max:     maxValue,
-			},
-		)
+	if accumulator.scheduled {
+		phases[PhaseCorrectedTotal] = accumulator.correctedTotal(runDuration)
 	}
 
-	return segments
+	return phases
+}
+
+// correctedTotal adds the missed dispatches to the corrected request time. A missed request
+// could not be sent until the end of the run, so it is counted from its schedule to the end.
+func (accumulator *urlAccumulator) correctedTotal(runDuration time.Duration) *durationAccumulator {
+	if accumulator.missedSchedule.count() == 0 {
+		return &accumulator.correctedRequestTime
+	}
+
+	corrected := &durationAccumulator{histogram: histogram.New()}
+
+	if accumulator.correctedRequestTime.count() > 0 {
+		corrected.histogram = accumulator.correctedRequestTime.histogram.Clone()
+	}
+
+	for _, bucket := range accumulator.missedSchedule.histogram.Buckets() {
+		corrected.histogram.RecordValues(int64(runDuration)-bucket.From, bucket.Count)
+	}
+
+	return corrected
+}
+
+func calculatePercentiles(phases map[string]*durationAccumulator, percentiles []float64) Percentiles {
+	results := make(Percentiles)
+
+	for phase, durations := range phases {
+		if durations.count() == 0 {
+			continue
+		}
+
+		results[phase] = make(map[string]time.Duration, len(percentiles))
+
+		for _, percentile := range percentiles {
+			results[phase][PercentileKey(percentile)] = durations.percentile(percentile)
+		}
+	}
+
+	return results
+}
+
+func exportHistograms(phases map[string]*durationAccumulator, enabled bool) map[string][]histogram.Bucket {
+	if !enabled {
+		return nil
+	}
+
+	results := make(map[string][]histogram.Bucket)
+
+	for phase, durations := range phases {
+		if durations.count() == 0 {
+			continue
+		}
+
+		results[phase] = durations.histogram.Buckets()
+	}
+
+	return results
+}
+
+func PercentileKey(percentile float64) string {
+	return "p" + strconv.FormatFloat(percentile, 'f', -1, 64)
+}
+
+// Keys returns the percentile names (p50, p90, ...) in ascending order
+func (percentiles Percentiles) Keys() []string {
+	keys := make([]string, 0)
+
+	for _, values := range percentiles {
+		for key := range values {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	slices.SortFunc(
+		keys, func(a, b string) int {
+			aValue, _ := strconv.ParseFloat(strings.TrimPrefix(a, "p"), 64)
+			bValue, _ := strconv.ParseFloat(strings.TrimPrefix(b, "p"), 64)
+
+			return cmp.Compare(aValue, bValue)
+		},
+	)
+
+	return keys
 }
@@ -0,0 +1,511 @@
+package statistics
+
+import (
+	"errors"
+	"github.com/vpominchuk/wmetrics/src/tester"
+	"math"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var testStart = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// newResult returns a request to the URL started at offset from testStart which took total
+func newResult(rawUrl string, offset, total time.Duration, statusCode int) tester.MeasurementResult {
+	requestUrl, _ := url.Parse(rawUrl)
+
+	result := tester.MeasurementResult{
+		RequestResult: tester.RequestResult{
+			Resource:   tester.Resource{Url: requestUrl},
+			StatusCode: statusCode,
+			Timing: tester.Timing{
+				Start:     testStart.Add(offset),
+				TotalTime: testStart.Add(offset + total),
+			},
+		},
+	}
+
+	result.RequestResult.Durations.Total.Total = total
+	result.RequestResult.Durations.TTFB.Duration = total / 2
+
+	return result
+}
+
+func failedResult(rawUrl string, offset, total time.Duration) tester.MeasurementResult {
+	result := newResult(rawUrl, offset, total, 503)
+	result.RequestResult.Error = &tester.HttpCodeError{Err: errors.New("HTTP code: 503")}
+
+	return result
+}
+
+func missedResult(rawUrl string, scheduled time.Duration) tester.MeasurementResult {
+	requestUrl, _ := url.Parse(rawUrl)
+
+	return tester.MeasurementResult{
+		RequestResult: tester.RequestResult{
+			Resource: tester.Resource{Url: requestUrl},
+			Dispatch: tester.Dispatch{Scheduled: testStart.Add(scheduled), Missed: true},
+		},
+	}
+}
+
+func collect(
+	t *testing.T, options Options, testDuration time.Duration, results ...tester.MeasurementResult,
+) Statistics {
+	t.Helper()
+
+	collector := NewCollector(options)
+	collector.RunStarted(testStart)
+
+	for _, result := range results {
+		collector.Consume(result)
+	}
+
+	stat, err := collector.GetStatistics(testDuration)
+
+	if err != nil {
+		t.Fatalf("GetStatistics() returned an error: %v", err)
+	}
+
+	return stat
+}
+
+// approximately compares the durations read from a histogram, which keeps the values with a precision of 0.2%
+func approximately(got, want time.Duration) bool {
+	return math.Abs(float64(got-want)) <= float64(want)*0.002
+}
+
+func TestCollectorCounts(t *testing.T) {
+	const testUrl = "http://localhost/"
+
+	warmup := newResult(testUrl, 0, 500*time.Millisecond, 200)
+	warmup.RequestResult.Dispatch.Warmup = true
+
+	reused := newResult(testUrl, 0, 10*time.Millisecond, 200)
+	reused.RequestResult.Connection.Reused = true
+	reused.RequestResult.BytesReceived = tester.BytesReceived{Headers: 100, Body: 400, Decoded: 1000}
+
+	asserted := newResult(testUrl, 0, 40*time.Millisecond, 200)
+	asserted.RequestResult.AssertionsChecked = true
+	asserted.RequestResult.FailedAssertions = []string{"time:30ms"}
+	asserted.Error = &tester.AssertionError{Assertions: asserted.RequestResult.FailedAssertions}
+
+	passed := newResult(testUrl, 0, 20*time.Millisecond, 200)
+	passed.RequestResult.AssertionsChecked = true
+
+	failed := failedResult(testUrl, 0, 5*time.Millisecond)
+
+	stat := collect(
+		t, Options{Assertions: []string{"time:30ms"}}, 2*time.Second,
+		warmup,
+		reused,
+		passed,
+		newResult(testUrl, 0, 30*time.Millisecond, 101),
+		newResult(testUrl, 0, 50*time.Millisecond, 302),
+		newResult(testUrl, 0, 60*time.Millisecond, 404),
+		newResult(testUrl, 0, 70*time.Millisecond, 503),
+		newResult(testUrl, 0, 80*time.Millisecond, 0),
+		failed,
+		failed,
+		asserted,
+		missedResult(testUrl, time.Second),
+	)[testUrl]
+
+	tests := []struct {
+		name      string
+		got, want int
+	}{
+		{"TotalRequests", stat.TotalRequests, 10},
+		{"SuccessRequests", stat.SuccessRequests, 7},
+		{"ErrorRequests", stat.ErrorRequests, 3},
+		{"Code1xx", stat.Code1xx, 1},
+		{"Code2xx", stat.Code2xx, 2},
+		{"Code3xx", stat.Code3xx, 1},
+		{"Code4xx", stat.Code4xx, 1},
+		{"Code5xx", stat.Code5xx, 1},
+		{"OtherCodes", stat.OtherCodes, 1},
+		{"ReusedConnections", stat.ReusedConnections, 1},
+		{"MissedDispatches", stat.MissedDispatches, 1},
+		{"Warmup.TotalRequests", stat.Warmup.TotalRequests, 1},
+		{"BytesReceived", int(stat.BytesReceived), 500},
+		{"ResponseSizeAvg", int(stat.ResponseSizeAvg), 40},
+		{"DecodedResponseSizeAvg", int(stat.DecodedResponseSizeAvg), 100},
+	}
+
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s = %d, want %d", test.name, test.got, test.want)
+		}
+	}
+
+	if stat.RequestTimeMin != 10*time.Millisecond || stat.RequestTimeMax != 80*time.Millisecond {
+		t.Errorf("RequestTimeMin, Max = %s, %s, want 10ms, 80ms", stat.RequestTimeMin, stat.RequestTimeMax)
+	}
+
+	if want := 320 * time.Millisecond / 7; !approximately(stat.RequestTimeAvg, want) {
+		t.Errorf("RequestTimeAvg = %s, want %s", stat.RequestTimeAvg, want)
+	}
+
+	if stat.Warmup.RequestTimeMax != 500*time.Millisecond {
+		t.Errorf("Warmup.RequestTimeMax = %s, want 500ms", stat.Warmup.RequestTimeMax)
+	}
+
+	wantErrors := []ErrorResult{
+		{Class: tester.ErrorClassHttpCode, Message: failed.RequestResult.Error.Error(), Count: 2},
+		{Class: tester.ErrorClassAssertion, Message: asserted.Error.Error(), Count: 1},
+	}
+
+	if len(stat.Errors) != len(wantErrors) {
+		t.Fatalf("Errors = %+v, want %+v", stat.Errors, wantErrors)
+	}
+
+	for index, want := range wantErrors {
+		if stat.Errors[index] != want {
+			t.Errorf("Errors[%d] = %+v, want %+v", index, stat.Errors[index], want)
+		}
+	}
+
+	if len(stat.Assertions) != 1 || stat.Assertions[0] != (AssertionResult{"time:30ms", 1, 1}) {
+		t.Errorf("Assertions = %+v, want time:30ms passed 1, failed 1", stat.Assertions)
+	}
+}
+
+func TestCollectorPercentiles(t *testing.T) {
+	const testUrl = "http://localhost/"
+
+	results := make([]tester.MeasurementResult, 0, 1000)
+
+	for index := 1; index <= 1000; index++ {
+		results = append(results, newResult(testUrl, 0, time.Duration(index)*time.Millisecond, 200))
+	}
+
+	stat := collect(t, Options{Percentiles: []float64{50, 99, 99.9}}, 10*time.Second, results...)[testUrl]
+
+	tests := []struct {
+		phase, key string
+		want       time.Duration
+	}{
+		{PhaseTotal, "p50", 500 * time.Millisecond},
+		{PhaseTotal, "p99", 990 * time.Millisecond},
+		{PhaseTotal, "p99.9", 999 * time.Millisecond},
+		{PhaseTTFB, "p50", 250 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		if got := stat.Percentiles[test.phase][test.key]; !approximately(got, test.want) {
+			t.Errorf("Percentiles[%s][%s] = %s, want %s", test.phase, test.key, got, test.want)
+		}
+	}
+
+	if _, ok := stat.Percentiles[PhaseCorrectedTotal]; ok {
+		t.Errorf("Percentiles has %s in the closed model", PhaseCorrectedTotal)
+	}
+
+	if stat.CoordinatedOmissionCorrection || stat.CorrectedRequestTimeMax != 0 {
+		t.Errorf(
+			"CoordinatedOmissionCorrection = %t, CorrectedRequestTimeMax = %s in the closed model",
+			stat.CoordinatedOmissionCorrection, stat.CorrectedRequestTimeMax,
+		)
+	}
+
+	if stat.Histograms != nil {
+		t.Errorf("Histograms exported without Options.Histograms")
+	}
+}
+
+func TestCollectorCorrectedTotal(t *testing.T) {
+	const testUrl = "http://localhost/"
+
+	// sent 100ms after its schedule, so it is corrected by the delay
+	delayed := newResult(testUrl, 100*time.Millisecond, 50*time.Millisecond, 200)
+	delayed.RequestResult.Dispatch.Scheduled = testStart
+
+	onTime := newResult(testUrl, time.Second, 50*time.Millisecond, 200)
+	onTime.RequestResult.Dispatch.Scheduled = testStart.Add(time.Second)
+
+	stat := collect(
+		t, Options{Percentiles: []float64{50, 100}, Histograms: true}, 4*time.Second,
+		delayed, onTime, missedResult(testUrl, 3*time.Second), missedResult(testUrl, 2*time.Second),
+	)[testUrl]
+
+	if !stat.CoordinatedOmissionCorrection {
+		t.Errorf("CoordinatedOmissionCorrection = false, want true")
+	}
+
+	if stat.RequestTimeMax != 50*time.Millisecond {
+		t.Errorf("RequestTimeMax = %s, want 50ms", stat.RequestTimeMax)
+	}
+
+	// the missed dispatches are counted until the end of the run, the earliest one took 2s
+	if stat.CorrectedRequestTimeMin != 50*time.Millisecond || !approximately(stat.CorrectedRequestTimeMax, 2*time.Second) {
+		t.Errorf(
+			"CorrectedRequestTimeMin, Max = %s, %s, want 50ms, 2s",
+			stat.CorrectedRequestTimeMin, stat.CorrectedRequestTimeMax,
+		)
+	}
+
+	if got := stat.Percentiles[PhaseCorrectedTotal]["p50"]; !approximately(got, 150*time.Millisecond) {
+		t.Errorf("corrected p50 = %s, want 150ms", got)
+	}
+
+	if got := stat.Percentiles[PhaseCorrectedTotal]["p100"]; !approximately(got, 2*time.Second) {
+		t.Errorf("corrected p100 = %s, want 2s", got)
+	}
+
+	count := int64(0)
+
+	for _, bucket := range stat.Histograms[PhaseCorrectedTotal] {
+		count += bucket.Count
+	}
+
+	if count != 4 || len(stat.Histograms[PhaseTotal]) != 1 {
+		t.Errorf("Histograms = %+v, want 4 corrected values and one bucket of the total", stat.Histograms)
+	}
+}
+
+func TestCollectorAllUrls(t *testing.T) {
+	single := collect(t, Options{}, time.Second, newResult("http://a/", 0, 10*time.Millisecond, 200))
+
+	if _, ok := single[AllUrls]; ok || len(single) != 1 {
+		t.Errorf("statistics of one URL = %v, want only the URL", single.Urls())
+	}
+
+	stat := collect(
+		t, Options{}, time.Second,
+		newResult("http://a/", 0, 10*time.Millisecond, 200),
+		newResult("http://a/", 0, 30*time.Millisecond, 200),
+		newResult("http://b/", 0, 50*time.Millisecond, 404),
+		failedResult("http://b/", 0, time.Millisecond),
+	)
+
+	tests := []struct {
+		url                            string
+		requests, errors, code2xx      int
+		requestTimeMin, requestTimeMax time.Duration
+	}{
+		{"http://a/", 2, 0, 2, 10 * time.Millisecond, 30 * time.Millisecond},
+		{"http://b/", 2, 1, 0, 50 * time.Millisecond, 50 * time.Millisecond},
+		{AllUrls, 4, 1, 2, 10 * time.Millisecond, 50 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		got, ok := stat[test.url]
+
+		if !ok {
+			t.Errorf("no statistics of %s", test.url)
+			continue
+		}
+
+		if got.TotalRequests != test.requests || got.ErrorRequests != test.errors || got.Code2xx != test.code2xx ||
+			got.RequestTimeMin != test.requestTimeMin || got.RequestTimeMax != test.requestTimeMax {
+			t.Errorf(
+				"%s: requests %d, errors %d, 2xx %d, time %s..%s, want %d, %d, %d, %s..%s", test.url,
+				got.TotalRequests, got.ErrorRequests, got.Code2xx, got.RequestTimeMin, got.RequestTimeMax,
+				test.requests, test.errors, test.code2xx, test.requestTimeMin, test.requestTimeMax,
+			)
+		}
+	}
+
+	if urls := stat.Urls(); len(urls) != 2 || urls[0] != "http://a/" || urls[1] != "http://b/" {
+		t.Errorf("Urls() = %v, want [http://a/ http://b/]", urls)
+	}
+
+	if urls := stat.UrlsSortedBy(SortByMedian); urls[0] != "http://a/" {
+		t.Errorf("UrlsSortedBy(median) = %v, want http://a/ first", urls)
+	}
+}
+
+func TestCollectorStages(t *testing.T) {
+	const testUrl = "http://localhost/"
+
+	stages := []tester.Stage{{Duration: 2 * time.Second, Target: 10}, {Duration: time.Second, Target: 0}}
+	results := make([]tester.MeasurementResult, 0)
+
+	for index, stage := range []int{1, 1, 1, 2, 2} {
+		result := newResult(testUrl, time.Duration(index)*500*time.Millisecond, 10*time.Millisecond, 200)
+		result.RequestResult.Dispatch.Stage = stage
+		results = append(results, result)
+	}
+
+	failed := failedResult(testUrl, 2500*time.Millisecond, time.Millisecond)
+	failed.RequestResult.Dispatch.Stage = 2
+	results = append(results, failed)
+
+	stat := collect(t, Options{Stages: stages}, 3*time.Second, results...)[testUrl]
+
+	want := []struct {
+		from, to, requests, errors int
+		rps                        float64
+	}{
+		{0, 10, 3, 0, 1.5},
+		{10, 0, 3, 1, 3},
+	}
+
+	if len(stat.Stages) != len(want) {
+		t.Fatalf("Stages = %+v, want %d stages", stat.Stages, len(want))
+	}
+
+	for index, stage := range stat.Stages {
+		rps := float64(stage.TotalRequests) / stage.TotalTime.Seconds()
+
+		if stage.Stage != index+1 || stage.From != want[index].from || stage.To != want[index].to ||
+			stage.TotalRequests != want[index].requests || stage.ErrorRequests != want[index].errors ||
+			rps != want[index].rps {
+			t.Errorf("Stages[%d] = %+v, want %+v", index, stage, want[index])
+		}
+	}
+
+	if stat.TotalRequests != 6 {
+		t.Errorf("TotalRequests = %d, want 6", stat.TotalRequests)
+	}
+}
+
+func TestCollectorTimeline(t *testing.T) {
+	const testUrl = "http://localhost/"
+
+	warmup := newResult(testUrl, -time.Second, 10*time.Millisecond, 200)
+	warmup.RequestResult.Dispatch.Warmup = true
+
+	stat := collect(
+		t, Options{TimelineInterval: time.Second, Percentiles: []float64{50}}, 3500*time.Millisecond,
+		warmup,
+		newResult(testUrl, 400*time.Millisecond, 100*time.Millisecond, 200),
+		newResult(testUrl, time.Second, 200*time.Millisecond, 200),
+		newResult(testUrl, 1500*time.Millisecond, 200*time.Millisecond, 500),
+		failedResult(testUrl, 1600*time.Millisecond, time.Millisecond),
+		missedResult(testUrl, 2500*time.Millisecond),
+		newResult(testUrl, 3*time.Second, 100*time.Millisecond, 200),
+	)[testUrl]
+
+	want := []struct {
+		offset, duration                  time.Duration
+		requests, errors, code2xx, missed int
+		median                            time.Duration
+	}{
+		{0, time.Second, 1, 0, 1, 0, 100 * time.Millisecond},
+		{time.Second, time.Second, 3, 1, 1, 0, 200 * time.Millisecond},
+		{2 * time.Second, time.Second, 0, 0, 0, 1, 0},
+		{3 * time.Second, 500 * time.Millisecond, 1, 0, 1, 0, 100 * time.Millisecond},
+	}
+
+	if len(stat.Timeline) != len(want) {
+		t.Fatalf("Timeline has %d buckets, want %d: %+v", len(stat.Timeline), len(want), stat.Timeline)
+	}
+
+	for index, bucket := range stat.Timeline {
+		test := want[index]
+
+		if bucket.Offset != test.offset || bucket.Duration != test.duration || bucket.TotalRequests != test.requests ||
+			bucket.ErrorRequests != test.errors || bucket.Code2xx != test.code2xx ||
+			bucket.MissedDispatches != test.missed || !approximately(bucket.RequestTimeMedian, test.median) ||
+			bucket.Percentiles["p50"] != bucket.RequestTimeMedian {
+			t.Errorf("Timeline[%d] = %+v, want %+v", index, bucket, test)
+		}
+	}
+
+	if rps := stat.Timeline[3].RequestsPerSecond; rps != 2 {
+		t.Errorf("RequestsPerSecond of the last, shorter bucket = %g, want 2", rps)
+	}
+}
+
+func TestRequestSamples(t *testing.T) {
+	const testUrl = "http://localhost/"
+
+	results := make([]tester.MeasurementResult, 0)
+
+	for _, total := range []time.Duration{30, 10, 50, 20, 40} {
+		result := newResult(testUrl, 0, total*time.Millisecond, 200)
+		result.RequestResult.Trace.TraceId = total.String()
+		results = append(results, result)
+	}
+
+	results = append(results, failedResult(testUrl, 0, time.Millisecond), missedResult(testUrl, 0))
+
+	stat := collect(t, Options{SlowestRequests: 3, FastestRequests: 2}, time.Second, results...)[testUrl]
+
+	tests := []struct {
+		name    string
+		samples []RequestSample
+		want    []time.Duration
+	}{
+		{"SlowestRequests", stat.SlowestRequests, []time.Duration{50, 40, 30}},
+		{"FastestRequests", stat.FastestRequests, []time.Duration{10, 20}},
+	}
+
+	for _, test := range tests {
+		if len(test.samples) != len(test.want) {
+			t.Errorf("%s has %d requests, want %d", test.name, len(test.samples), len(test.want))
+			continue
+		}
+
+		for index, sample := range test.samples {
+			want := test.want[index] * time.Millisecond
+
+			if sample.Durations.Total.Total != want || sample.TraceId != test.want[index].String() {
+				t.Errorf("%s[%d] = %s (trace %s), want %s", test.name, index, sample.Durations.Total.Total, sample.TraceId, want)
+			}
+		}
+	}
+}
+
+func TestRequestHeap(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		slowest bool
+		totals  []time.Duration
+		want    []time.Duration
+	}{
+		{"slowest", 3, true, []time.Duration{5, 1, 9, 3, 7, 2}, []time.Duration{9, 7, 5}},
+		{"fastest", 3, false, []time.Duration{5, 1, 9, 3, 7, 2}, []time.Duration{1, 2, 3}},
+		{"fewer than the limit", 5, true, []time.Duration{2, 8}, []time.Duration{8, 2}},
+		{"no limit", 0, true, []time.Duration{2, 8}, []time.Duration{}},
+	}
+
+	for _, test := range tests {
+		requests := newRequestHeap(test.limit, test.slowest)
+
+		for _, total := range test.totals {
+			requests.add(newResult("http://localhost/", 0, total, 200))
+		}
+
+		sorted := requests.sorted()
+
+		if len(sorted) != len(test.want) {
+			t.Errorf("%s: sorted() returned %d requests, want %d", test.name, len(sorted), len(test.want))
+			continue
+		}
+
+		for index, result := range sorted {
+			if requestTime(result) != test.want[index] {
+				t.Errorf("%s: sorted()[%d] = %d, want %d", test.name, index, requestTime(result), test.want[index])
+			}
+		}
+	}
+}
+
+func TestPercentileKeys(t *testing.T) {
+	percentiles := Percentiles{
+		PhaseTotal: {"p99.9": 0, "p50": 0, "p99": 0},
+		PhaseTTFB:  {"p90": 0, "p50": 0},
+	}
+
+	want := []string{"p50", "p90", "p99", "p99.9"}
+	keys := percentiles.Keys()
+
+	if len(keys) != len(want) {
+		t.Fatalf("Keys() = %v, want %v", keys, want)
+	}
+
+	for index, key := range keys {
+		if key != want[index] {
+			t.Errorf("Keys() = %v, want %v", keys, want)
+			break
+		}
+	}
+
+	if key := PercentileKey(99.9); key != "p99.9" {
+		t.Errorf("PercentileKey(99.9) = %q, want p99.9", key)
+	}
+}
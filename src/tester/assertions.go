@@ -0,0 +1,248 @@
+package tester
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/vpominchuk/wmetrics/src/helpers"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AssertionStatus       = "status"
+	AssertionHeader       = "header"
+	AssertionBodyContains = "body-contains"
+	AssertionBodyRegex    = "body-regex"
+	AssertionJson         = "json"
+	AssertionTime         = "time"
+)
+
+var statusCodePattern = regexp.MustCompile("^[1-5]([0-9]{2}|xx)$")
+
+// maxAssertionBodySize limits the part of the response body kept in memory for the body assertions
+const maxAssertionBodySize = 10 << 20
+
+// Assertion is a check of every response (-A). Failed assertions fail the request.
+type Assertion struct {
+	// Name is the assertion as declared, e.g. "status:2xx"
+	Name string
+	Kind string
+
+	// Target is the header name or the JSON path
+	Target   string
+	Value    string
+	HasValue bool
+
+	codes  []string
+	regexp *regexp.Regexp
+	time   time.Duration
+}
+
+// ParseAssertion parses the assertion declarations:
+//
+//	status:200,201,3xx
+//	header:Name or header:Name=value
+//	body-contains:text
+//	body-regex:expression
+//	json:path.to.0.field or json:path.to.0.field=value
+//	time:300ms
+func ParseAssertion(value string) (Assertion, error) {
+	kind, argument, found := strings.Cut(value, ":")
+
+	if !found || argument == "" {
+		return Assertion{}, fmt.Errorf("invalid assertion: %s. Expected format is kind:argument", value)
+	}
+
+	assertion := Assertion{
+		Name: value,
+		Kind: kind,
+	}
+
+	switch kind {
+	case AssertionStatus:
+		for _, code := range strings.Split(argument, ",") {
+			code = strings.ToLower(strings.TrimSpace(code))
+
+			if !statusCodePattern.MatchString(code) {
+				return Assertion{}, fmt.Errorf("invalid status code in assertion: %s", value)
+			}
+
+			assertion.codes = append(assertion.codes, code)
+		}
+	case AssertionHeader, AssertionJson:
+		assertion.Target, assertion.Value, assertion.HasValue = strings.Cut(argument, "=")
+	case AssertionBodyContains:
+		assertion.Value = argument
+	case AssertionBodyRegex:
+		expression, err := regexp.Compile(argument)
+
+		if err != nil {
+			return Assertion{}, fmt.Errorf("invalid regular expression in assertion: %s. Error: %v", value, err)
+		}
+
+		assertion.regexp = expression
+	case AssertionTime:
+		duration, err := time.ParseDuration(argument)
+
+		if err != nil || duration <= 0 {
+			return Assertion{}, fmt.Errorf("invalid time in assertion: %s", value)
+		}
+
+		assertion.time = duration
+	default:
+		return Assertion{}, fmt.Errorf(
+			"invalid assertion: %s. Allowed kinds are: %v", value,
+			[]string{
+				AssertionStatus, AssertionHeader, AssertionBodyContains, AssertionBodyRegex, AssertionJson,
+				AssertionTime,
+			},
+		)
+	}
+
+	return assertion, nil
+}
+
+func (assertion Assertion) needsBody() bool {
+	return assertion.Kind == AssertionBodyContains || assertion.Kind == AssertionBodyRegex ||
+		assertion.Kind == AssertionJson
+}
+
+func needsBody(assertions []Assertion) bool {
+	for _, assertion := range assertions {
+		if assertion.needsBody() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (assertion Assertion) check(response *http.Response, body []byte, result RequestResult) bool {
+	switch assertion.Kind {
+	case AssertionStatus:
+		for _, code := range assertion.codes {
+			if statusCodeMatches(code, response.StatusCode) {
+				return true
+			}
+		}
+
+		return false
+	case AssertionHeader:
+		values := response.Header.Values(assertion.Target)
+
+		if !assertion.HasValue {
+			return len(values) > 0
+		}
+
+		for _, value := range values {
+			if value == assertion.Value {
+				return true
+			}
+		}
+
+		return false
+	case AssertionBodyContains:
+		return bytes.Contains(body, []byte(assertion.Value))
+	case AssertionBodyRegex:
+		return assertion.regexp.Match(body)
+	case AssertionJson:
+		return assertion.checkJson(body)
+	case AssertionTime:
+		return result.Durations.Total.Total < assertion.time
+	}
+
+	return false
+}
+
+// checkJson walks the dot separated path, numeric parts of the path are array indexes.
+// Strings are compared as is, other values in their JSON form.
+func (assertion Assertion) checkJson(body []byte) bool {
+	var node any
+
+	if err := json.Unmarshal(body, &node); err != nil {
+		return false
+	}
+
+	for _, key := range strings.Split(assertion.Target, ".") {
+		switch value := node.(type) {
+		case map[string]any:
+			var ok bool
+
+			if node, ok = value[key]; !ok {
+				return false
+			}
+		case []any:
+			index, err := strconv.Atoi(key)
+
+			if err != nil || index < 0 || index >= len(value) {
+				return false
+			}
+
+			node = value[index]
+		default:
+			return false
+		}
+	}
+
+	if !assertion.HasValue {
+		return true
+	}
+
+	if value, ok := node.(string); ok {
+		return value == assertion.Value
+	}
+
+	encoded, err := json.Marshal(node)
+
+	return err == nil && string(encoded) == assertion.Value
+}
+
+// checkAssertions returns the names of the failed assertions
+func checkAssertions(assertions []Assertion, response *http.Response, body []byte, result RequestResult) []string {
+	var failed []string
+
+	for _, assertion := range assertions {
+		if !assertion.check(response, body, result) {
+			failed = append(failed, assertion.Name)
+		}
+	}
+
+	return failed
+}
+
+// statusCodeMatches compares the status code to an exact code (404) or a class of codes (4xx)
+func statusCodeMatches(code string, statusCode int) bool {
+	if intCode, err := strconv.Atoi(code); err == nil {
+		return statusCode == intCode
+	}
+
+	if match, err := helpers.RegexpStringMatch("(?i)^[0-9]{1}xx$", code); err == nil && match {
+		if intCode, err := strconv.Atoi(code[:1]); err == nil {
+			return statusCode >= intCode*100 && statusCode < (intCode+1)*100
+		}
+	}
+
+	return false
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest
+type limitedBuffer struct {
+	buffer bytes.Buffer
+	limit  int
+}
+
+func (buffer *limitedBuffer) Write(p []byte) (int, error) {
+	if room := buffer.limit - buffer.buffer.Len(); room > 0 {
+		buffer.buffer.Write(p[:min(room, len(p))])
+	}
+
+	return len(p), nil
+}
+
+func (buffer *limitedBuffer) Bytes() []byte {
+	return buffer.buffer.Bytes()
+}
@@ -0,0 +1,113 @@
+package tester
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseAssertion(t *testing.T) {
+	tests := []struct {
+		value    string
+		kind     string
+		target   string
+		expected string
+		hasValue bool
+		codes    []string
+		time     time.Duration
+	}{
+		{value: "status:200", kind: AssertionStatus, codes: []string{"200"}},
+		{value: "status:200, 201,3XX", kind: AssertionStatus, codes: []string{"200", "201", "3xx"}},
+		{value: "header:Content-Type", kind: AssertionHeader, target: "Content-Type"},
+		{
+			value: "header:Content-Type=application/json", kind: AssertionHeader, target: "Content-Type",
+			expected: "application/json", hasValue: true,
+		},
+		{value: "header:X-Empty=", kind: AssertionHeader, target: "X-Empty", hasValue: true},
+		{value: "body-contains:a=b:c", kind: AssertionBodyContains, expected: "a=b:c"},
+		{value: "body-regex:^ok$", kind: AssertionBodyRegex},
+		{value: "json:data.items.0.id", kind: AssertionJson, target: "data.items.0.id"},
+		{value: "json:data.id=5", kind: AssertionJson, target: "data.id", expected: "5", hasValue: true},
+		{value: "time:300ms", kind: AssertionTime, time: 300 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		assertion, err := ParseAssertion(test.value)
+
+		if err != nil {
+			t.Errorf("ParseAssertion(%q) returned an error: %v", test.value, err)
+			continue
+		}
+
+		if assertion.Name != test.value || assertion.Kind != test.kind || assertion.Target != test.target ||
+			assertion.Value != test.expected || assertion.HasValue != test.hasValue ||
+			!slices.Equal(assertion.codes, test.codes) || assertion.time != test.time {
+			t.Errorf("ParseAssertion(%q) = %+v", test.value, assertion)
+		}
+
+		if (assertion.Kind == AssertionBodyRegex) != (assertion.regexp != nil) {
+			t.Errorf("ParseAssertion(%q) regexp = %v", test.value, assertion.regexp)
+		}
+	}
+}
+
+func TestParseAssertionInvalid(t *testing.T) {
+	for _, value := range []string{
+		"", "status", "status:", "status:600", "status:20", "status:2xy", "body-regex:(", "time:fast", "time:0s",
+		"time:-1s", "cookie:session",
+	} {
+		if _, err := ParseAssertion(value); err == nil {
+			t.Errorf("ParseAssertion(%q) did not return an error", value)
+		}
+	}
+}
+
+func TestAssertionCheck(t *testing.T) {
+	response := &http.Response{
+		StatusCode: 201,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+	}
+
+	body := []byte(`{"data": {"id": 5, "name": "wmetrics", "items": [{"id": "a"}, {"id": "b"}], "ok": true}}`)
+
+	result := RequestResult{}
+	result.Durations.Total.Total = 200 * time.Millisecond
+
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"status:201", true},
+		{"status:2xx", true},
+		{"status:200,3xx", false},
+		{"header:content-type", true},
+		{"header:Content-Type=application/json", true},
+		{"header:Content-Type=text/html", false},
+		{"header:X-Missing", false},
+		{"body-contains:wmetrics", true},
+		{"body-contains:missing", false},
+		{"body-regex:\"id\":\\s*5", true},
+		{"json:data.id=5", true},
+		{"json:data.id=6", false},
+		{"json:data.name=wmetrics", true},
+		{"json:data.items.1.id=b", true},
+		{"json:data.items.2", false},
+		{"json:data.ok=true", true},
+		{"json:data.missing", false},
+		{"time:300ms", true},
+		{"time:200ms", false},
+	}
+
+	for _, test := range tests {
+		assertion, err := ParseAssertion(test.value)
+
+		if err != nil {
+			t.Fatalf("ParseAssertion(%q) returned an error: %v", test.value, err)
+		}
+
+		if got := assertion.check(response, body, result); got != test.want {
+			t.Errorf("check of %q = %t, want %t", test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package tester
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	ConnectionPoolShared = "shared"
+	ConnectionPoolWorker = "worker"
+)
+
+type clientPool struct {
+	parameters   Parameters
+	certificates []tls.Certificate
+	shared       *http.Client
+	workers      map[int]*http.Client
+	mutex        sync.Mutex
+}
+
+func (engine *HttpEngine) newClientPool(parameters Parameters) (*clientPool, error) {
+	certificates, err := engine.readClientPemCertificate(parameters.ClientCertificateFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &clientPool{
+		parameters:   parameters,
+		certificates: certificates,
+		workers:      make(map[int]*http.Client),
+	}
+
+	if parameters.ConnectionPool != ConnectionPoolWorker {
+		pool.shared = pool.newClient()
+	}
+
+	return pool, nil
+}
+
+func (pool *clientPool) get(workerId int) *http.Client {
+	if pool.shared != nil {
+		return pool.shared
+	}
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	client, ok := pool.workers[workerId]
+
+	if !ok {
+		client = pool.newClient()
+		pool.workers[workerId] = client
+	}
+
+	return client
+}
+
+func (pool *clientPool) closeIdleConnections() {
+	if pool.shared != nil {
+		pool.shared.CloseIdleConnections()
+	}
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	for _, client := range pool.workers {
+		client.CloseIdleConnections()
+	}
+}
+
+func (pool *clientPool) newClient() *http.Client {
+	parameters := pool.parameters
+	proxyURL, _ := url.Parse(parameters.Proxy)
+
+	var network string
+
+	if parameters.IPv4Only {
+		network = "tcp4"
+	} else if parameters.IPv6Only {
+		network = "tcp6"
+	} else {
+		network = "tcp4"
+	}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return (&net.Dialer{
+				Timeout:   parameters.Timeout,
+				KeepAlive: parameters.IdleConnTimeout,
+			}).DialContext(ctx, network, addr)
+		},
+		MaxIdleConns:          parameters.MaxIdleConnections,
+		MaxIdleConnsPerHost:   parameters.MaxIdleConnections,
+		MaxConnsPerHost:       0,
+		IdleConnTimeout:       parameters.IdleConnTimeout,
+		ResponseHeaderTimeout: parameters.Timeout,
+		DisableKeepAlives:     !parameters.KeepAlive,
+		TLSHandshakeTimeout:   parameters.TLSHandshakeTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     true,
+		DisableCompression:    true,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: parameters.AllowInsecureSSL,
+			Certificates:       pool.certificates,
+			MinVersion:         tls.VersionTLS12,
+		},
+	}
+
+	if parameters.Proxy != "" {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// do not follow redirects
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -1,6 +1,30 @@
 package tester
 
-import "fmt"
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+)
+
+const (
+	ErrorClassDNS                   = "dns"
+	ErrorClassConnectionRefused     = "connection_refused"
+	ErrorClassConnectTimeout        = "connect_timeout"
+	ErrorClassTLS                   = "tls"
+	ErrorClassResponseHeaderTimeout = "response_header_timeout"
+	ErrorClassTimeout               = "timeout"
+	ErrorClassConnectionReset       = "connection_reset"
+	ErrorClassProxy                 = "proxy"
+	ErrorClassHttpCode              = "http_code"
+	ErrorClassAssertion             = "assertion"
+	ErrorClassCanceled              = "canceled"
+	ErrorClassOther                 = "other"
+)
 
 type ResponseError struct {
 	Message string
@@ -11,6 +35,10 @@ func (r *ResponseError) Error() string {
 	return fmt.Sprintf("%s. Error: %v", r.Message, r.Err)
 }
 
+func (r *ResponseError) Unwrap() error {
+	return r.Err
+}
+
 type PostDataFileError struct {
 	FileName string
 	Err      error
@@ -20,6 +48,10 @@ func (r *PostDataFileError) Error() string {
 	return fmt.Sprintf("[%s], %v", r.FileName, r.Err)
 }
 
+func (r *PostDataFileError) Unwrap() error {
+	return r.Err
+}
+
 type HttpCodeError struct {
 	Err error
 }
@@ -27,3 +59,95 @@ type HttpCodeError struct {
 func (r *HttpCodeError) Error() string {
 	return fmt.Sprintf("Error: %v", r.Err)
 }
+
+func (r *HttpCodeError) Unwrap() error {
+	return r.Err
+}
+
+type AssertionError struct {
+	Assertions []string
+}
+
+func (r *AssertionError) Error() string {
+	return fmt.Sprintf("Assertion failed: %s", strings.Join(r.Assertions, ", "))
+}
+
+// RecordedError is an error restored from the raw result log, its class has been determined when it occurred
+type RecordedError struct {
+	Class   string
+	Message string
+}
+
+func (r *RecordedError) Error() string {
+	return r.Message
+}
+
+// ClassifyError returns the class of a request failure (ErrorClassDNS, ErrorClassConnectionRefused, ...),
+// so errors which differ only by addresses or ports can be counted together
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var recordedError *RecordedError
+	var httpCodeError *HttpCodeError
+	var assertionError *AssertionError
+	var dnsError *net.DNSError
+	var opError *net.OpError
+
+	switch {
+	case errors.As(err, &recordedError):
+		return recordedError.Class
+	case errors.As(err, &httpCodeError):
+		return ErrorClassHttpCode
+	case errors.As(err, &assertionError):
+		return ErrorClassAssertion
+	case errors.As(err, &dnsError):
+		return ErrorClassDNS
+	case errors.As(err, &opError) && (opError.Op == "proxyconnect" || opError.Op == "socks connect"):
+		return ErrorClassProxy
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorClassConnectionRefused
+	case errors.As(err, &opError) && opError.Op == "dial" && opError.Timeout():
+		return ErrorClassConnectTimeout
+	case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE):
+		return ErrorClassConnectionReset
+	case isTLSError(err):
+		return ErrorClassTLS
+	// net/http does not export the error of Transport.ResponseHeaderTimeout
+	case strings.Contains(err.Error(), "timeout awaiting response headers"):
+		return ErrorClassResponseHeaderTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case isTimeout(err):
+		return ErrorClassTimeout
+	}
+
+	return ErrorClassOther
+}
+
+func isTLSError(err error) bool {
+	var recordHeaderError tls.RecordHeaderError
+	var alertError tls.AlertError
+	var certificateVerificationError *tls.CertificateVerificationError
+	var unknownAuthorityError x509.UnknownAuthorityError
+	var hostnameError x509.HostnameError
+	var certificateInvalidError x509.CertificateInvalidError
+
+	return errors.As(err, &recordHeaderError) ||
+		errors.As(err, &alertError) ||
+		errors.As(err, &certificateVerificationError) ||
+		errors.As(err, &unknownAuthorityError) ||
+		errors.As(err, &hostnameError) ||
+		errors.As(err, &certificateInvalidError) ||
+		// handshake timeouts and most handshake failures are not typed
+		strings.Contains(err.Error(), "TLS handshake") ||
+		strings.Contains(err.Error(), "HTTP response to HTTPS client") ||
+		strings.Contains(err.Error(), "tls: ")
+}
+
+func isTimeout(err error) bool {
+	var timeoutError interface{ Timeout() bool }
+
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &timeoutError) && timeoutError.Timeout())
+}
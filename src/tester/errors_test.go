@@ -0,0 +1,117 @@
+package tester
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func urlError(err error) error {
+	return &url.Error{Op: "Get", URL: "http://example.com/", Err: err}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"no error", nil, ""},
+		{"recorded", &RecordedError{Class: ErrorClassTLS, Message: "tls: bad certificate"}, ErrorClassTLS},
+		{"http code", &HttpCodeError{Err: errors.New("500 Internal Server Error")}, ErrorClassHttpCode},
+		{"assertion", &AssertionError{Assertions: []string{"status:2xx"}}, ErrorClassAssertion},
+		{
+			"dns",
+			urlError(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "example.invalid"}}),
+			ErrorClassDNS,
+		},
+		{
+			"dns in a response error",
+			&ResponseError{Message: "request failed", Err: &net.DNSError{Err: "server misbehaving", Name: "example.com"}},
+			ErrorClassDNS,
+		},
+		{"proxy", urlError(&net.OpError{Op: "proxyconnect", Net: "tcp", Err: errors.New("refused")}), ErrorClassProxy},
+		{
+			"connection refused",
+			urlError(&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}),
+			ErrorClassConnectionRefused,
+		},
+		{
+			"connect timeout",
+			urlError(&net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}),
+			ErrorClassConnectTimeout,
+		},
+		{
+			"reset by peer",
+			urlError(&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}),
+			ErrorClassConnectionReset,
+		},
+		{
+			"broken pipe",
+			urlError(&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}),
+			ErrorClassConnectionReset,
+		},
+		{
+			"tls record header",
+			urlError(tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}),
+			ErrorClassTLS,
+		},
+		{"unknown authority", urlError(x509.UnknownAuthorityError{}), ErrorClassTLS},
+		{"tls handshake timeout", urlError(errors.New("net/http: TLS handshake timeout")), ErrorClassTLS},
+		{
+			"response header timeout",
+			urlError(errors.New("net/http: timeout awaiting response headers")),
+			ErrorClassResponseHeaderTimeout,
+		},
+		{"canceled", urlError(fmt.Errorf("aborted: %w", context.Canceled)), ErrorClassCanceled},
+		{"deadline", urlError(context.DeadlineExceeded), ErrorClassTimeout},
+		{"read timeout", urlError(&net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}), ErrorClassTimeout},
+		{"other", errors.New("unexpected EOF"), ErrorClassOther},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				if got := ClassifyError(test.err); got != test.want {
+					t.Errorf("ClassifyError(%v) = %q, want %q", test.err, got, test.want)
+				}
+			},
+		)
+	}
+}
+
+func TestClassifyDialError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+
+	address := listener.Addr().String()
+	_ = listener.Close()
+
+	connection, err := net.DialTimeout("tcp", address, time.Second)
+
+	if err == nil {
+		_ = connection.Close()
+		t.Skipf("%s accepted a connection after the listener was closed", address)
+	}
+
+	if got := ClassifyError(err); got != ErrorClassConnectionRefused {
+		t.Errorf("ClassifyError(%v) = %q, want %q", err, got, ErrorClassConnectionRefused)
+	}
+}
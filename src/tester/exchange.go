@@ -0,0 +1,136 @@
+package tester
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exchange is the dump of a request and its response, captured for the first requests (-v)
+// and for every K-th request (-vs) in the verbose mode
+type Exchange struct {
+	// Sequence is the number of the request in the order the requests were sent, starting from 1
+	Sequence int64
+
+	Method,
+	Host,
+	RequestUri string
+	RequestHeaders http.Header
+	RequestBody    BodyExcerpt
+
+	// Proto is the protocol of the request, or the protocol negotiated with the server if there is a response
+	Proto,
+	Status string
+	ResponseHeaders http.Header
+	ResponseBody    BodyExcerpt
+
+	// Events are the connection events of the request (DNS lookup, connect, TLS handshake, ...) in order
+	Events []ExchangeEvent
+}
+
+// BodyExcerpt is the beginning of a body, Size is the size of the whole (decoded) body
+type BodyExcerpt struct {
+	Content []byte
+	Size    int64
+}
+
+type ExchangeEvent struct {
+	Time    time.Time
+	Message string
+}
+
+// bodyCapture keeps the beginning of the request body while the transport sends it,
+// the transport may still be reading the body when the response arrives
+type bodyCapture struct {
+	io.ReadCloser
+	mutex  sync.Mutex
+	buffer limitedBuffer
+	size   int64
+}
+
+// dumpSequence returns the sequence number of the request if it is dumped in the verbose mode, 0 otherwise
+func (engine *HttpEngine) dumpSequence(parameters Parameters) int64 {
+	if parameters.DumpRequests == 0 && parameters.DumpSample == 0 {
+		return 0
+	}
+
+	sequence := engine.requestSequence.Add(1)
+
+	if sequence <= int64(parameters.DumpRequests) ||
+		(parameters.DumpSample > 0 && sequence%int64(parameters.DumpSample) == 0) {
+		return sequence
+	}
+
+	return 0
+}
+
+// captureRequest dumps the request as it is about to be sent, the body is captured while it is sent
+func (exchange *Exchange) captureRequest(request *http.Request, bodySize int) *bodyCapture {
+	exchange.Method = request.Method
+	exchange.Host = request.Host
+	exchange.RequestUri = request.URL.RequestURI()
+	exchange.Proto = request.Proto
+	exchange.RequestHeaders = request.Header.Clone()
+
+	if exchange.Host == "" {
+		exchange.Host = request.URL.Host
+	}
+
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil
+	}
+
+	capture := &bodyCapture{
+		ReadCloser: request.Body,
+		buffer:     limitedBuffer{limit: bodySize},
+	}
+
+	request.Body = capture
+
+	return capture
+}
+
+func (exchange *Exchange) captureResponse(response *http.Response, body []byte, size int64, bodySize int) {
+	exchange.Proto = response.Proto
+	exchange.Status = response.Status
+	exchange.ResponseHeaders = response.Header.Clone()
+	exchange.ResponseBody = BodyExcerpt{
+		Content: bytes.Clone(body[:min(len(body), bodySize)]),
+		Size:    size,
+	}
+}
+
+func joinAddresses(addresses []net.IPAddr) string {
+	formatted := make([]string, 0, len(addresses))
+
+	for _, address := range addresses {
+		formatted = append(formatted, address.String())
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+func (capture *bodyCapture) Read(p []byte) (int, error) {
+	count, err := capture.ReadCloser.Read(p)
+
+	capture.mutex.Lock()
+	_, _ = capture.buffer.Write(p[:count])
+	capture.size += int64(count)
+	capture.mutex.Unlock()
+
+	return count, err
+}
+
+func (capture *bodyCapture) excerpt() BodyExcerpt {
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+
+	return BodyExcerpt{
+		Content: bytes.Clone(capture.buffer.Bytes()),
+		Size:    capture.size,
+	}
+}
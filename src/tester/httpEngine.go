@@ -6,32 +6,42 @@ import (
 	"encoding/pem"
 	"fmt"
 	"github.com/vpominchuk/wmetrics/src/app"
-	"github.com/vpominchuk/wmetrics/src/helpers"
 	"io"
 	"log"
-	"net"
 	"net/http"
 	"net/http/httptrace"
-	"net/url"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	minDispatchLag = 1 * time.Millisecond
+	maxDispatchLag = 10 * time.Millisecond
+)
+
 type HttpEngine struct {
 	Progress       RequestsProgress
 	progressMutex  sync.Mutex
 	resourceFeeder *ResourceFeeder
+	clients        *clientPool
+	observers      []RequestObserver
+
+	// requestSequence numbers the requests to select the ones dumped in the verbose mode
+	requestSequence atomic.Int64
 }
 
 func (engine *HttpEngine) Measure(
+	ctx context.Context,
 	parameters Parameters,
 	resourceFeeder *ResourceFeeder,
+	sinks []ResultSink,
 	onProgress func(progress RequestsProgress),
-) ([]MeasurementResult, time.Duration) {
+) time.Duration {
 	engine.resourceFeeder = resourceFeeder
+	engine.observers = requestObservers(sinks)
 
 	engine.Progress = RequestsProgress{
 		TotalRequests:     parameters.Requests,
@@ -41,59 +51,262 @@ func (engine *HttpEngine) Measure(
 
 	parameters.Method = strings.ToUpper(parameters.Method)
 
-	results := make([]MeasurementResult, 0, parameters.Requests)
-	concurrencyCh := make(chan bool, parameters.Concurrency)
+	clients, err := engine.newClientPool(parameters)
+
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	engine.clients = clients
+	defer engine.clients.closeIdleConnections()
+
+	var lastOnProgressCalled time.Time
+
+	pipeline := newResultPipeline(
+		sinks, parameters.Concurrency, func(result MeasurementResult) {
+			if result.RequestResult.Dispatch.Warmup {
+				return
+			}
+
+			engine.updateProgress(result.Error != nil)
+
+			if time.Since(lastOnProgressCalled) >= time.Second {
+				lastOnProgressCalled = time.Now()
+				onProgress(engine.GetProgress())
+			}
+		},
+	)
+
+	pipeline.start()
+
+	var testDuration time.Duration
+
+	if parameters.WarmupRequests > 0 || parameters.WarmupDuration > 0 {
+		engine.run(ctx, newTestRun(getWarmupParameters(parameters), pipeline, true))
+	}
+
+	if ctx.Err() == nil {
+		testDuration = engine.run(ctx, newTestRun(parameters, pipeline, false))
+	}
+
+	pipeline.close()
+
+	onProgress(engine.GetProgress())
+	return testDuration
+}
+
+// getWarmupParameters returns parameters of the warm-up phase. It uses the full concurrency
+// and the rate of the test, but neither load stages nor the number of requests and the time limit.
+func getWarmupParameters(parameters Parameters) Parameters {
+	parameters.Requests = parameters.WarmupRequests
+	parameters.TimeLimit = parameters.WarmupDuration
+	parameters.Stages = nil
+
+	return parameters
+}
+
+func (engine *HttpEngine) run(ctx context.Context, run *testRun) time.Duration {
+	parameters := run.parameters
+	defer run.cancelRequests()
+
+	if len(parameters.Stages) > 0 {
+		run.stages = newStageController(parameters.Stages, run.concurrencyCh)
+	} else {
+		for workerId := 0; workerId < parameters.Concurrency; workerId++ {
+			run.concurrencyCh <- workerId
+		}
+	}
+
+	run.startTime = time.Now()
+
+	if !run.warmup {
+		run.pipeline.runStarted(run.startTime)
+	}
+
+	if parameters.TimeLimit > 0 {
+		stopTimer := time.AfterFunc(parameters.TimeLimit, run.stop)
+		defer stopTimer.Stop()
+	}
+
+	stopOnCancel := context.AfterFunc(ctx, run.stop)
+	defer stopOnCancel()
+
+	if run.stages != nil {
+		run.stages.start(run.startTime)
+	}
+
+	if parameters.Rate > 0 {
+		engine.dispatchAtRate(run)
+	} else {
+		engine.dispatch(run)
+	}
+
+	if run.stages != nil {
+		run.stages.close()
+	}
+
+	engine.waitForRequests(ctx, run)
+	close(run.concurrencyCh)
+
+	return time.Since(run.startTime)
+}
+
+// dispatch sends the next request as soon as one of the workers is free (closed model)
+func (engine *HttpEngine) dispatch(run *testRun) {
+	for requestNumber := 0; engine.canDispatch(run, requestNumber); requestNumber++ {
+		select {
+		case workerId := <-run.concurrencyCh:
+			engine.execute(run, workerId, Dispatch{Stage: run.stageAt(time.Now())})
+		case <-run.stopped:
+			return
+		}
+	}
+}
 
-	var wg sync.WaitGroup
-	var lastOnProgressCalled int64 = 0
+// dispatchAtRate sends requests on a fixed schedule regardless of response times (open model).
+// If all the workers are busy the request is dropped as missed right away, so the schedule is kept.
+// A request sent behind its schedule, e.g. because the scheduler was delayed, is reported as late.
+func (engine *HttpEngine) dispatchAtRate(run *testRun) {
+	interval := time.Duration(float64(time.Second) / run.parameters.Rate)
 
-	testStartTime := time.Now()
+	for requestNumber := 0; engine.canDispatch(run, requestNumber); requestNumber++ {
+		scheduled := run.startTime.Add(time.Duration(requestNumber) * interval)
 
-	for requestNumber := 0; !engine.timeLimitReached(
-		testStartTime, parameters.TimeLimit,
-	) || requestNumber < parameters.Requests; requestNumber++ {
-		if parameters.TimeLimit > 0 && engine.timeLimitReached(testStartTime, parameters.TimeLimit) {
+		if !run.sleepUntil(scheduled) || !engine.canDispatch(run, requestNumber) {
 			break
 		}
 
-		concurrencyCh <- true
-		wg.Add(1)
+		select {
+		case workerId := <-run.concurrencyCh:
+			engine.execute(run, workerId, newDispatch(scheduled, interval))
+		default:
+			engine.missDispatch(run, scheduled)
+		}
+	}
+}
 
-		go func() {
-			defer func() {
-				<-concurrencyCh
-				wg.Done()
-			}()
+// waitForRequests waits for the requests in flight. If the test has been cancelled
+// they are given the grace period to complete, after that they are aborted.
+func (engine *HttpEngine) waitForRequests(ctx context.Context, run *testRun) {
+	done := make(chan bool)
 
-			if parameters.TimeLimit > 0 && engine.timeLimitReached(testStartTime, parameters.TimeLimit) {
-				return
-			}
+	go func() {
+		run.wg.Wait()
+		close(done)
+	}()
 
-			result, err := engine.request(parameters)
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
 
-			engine.updateProgress(err != nil)
+	graceTimer := time.NewTimer(run.parameters.GracePeriod)
+	defer graceTimer.Stop()
 
-			if time.Now().UnixNano()-lastOnProgressCalled >= int64(time.Second) {
-				lastOnProgressCalled = time.Now().UnixNano()
-				onProgress(engine.Progress)
-			}
+	select {
+	case <-done:
+	case <-graceTimer.C:
+		run.cancelRequests()
+		<-done
+	}
+}
 
-			engine.processHttpCodes(parameters, &result)
+func (engine *HttpEngine) canDispatch(run *testRun, requestNumber int) bool {
+	if run.isStopped() {
+		return false
+	}
 
-			results = append(
-				results, MeasurementResult{
-					RequestResult: result,
-					Error:         err,
-				},
-			)
+	if run.parameters.TimeLimit > 0 {
+		return !engine.timeLimitReached(run.startTime, run.parameters.TimeLimit)
+	}
+
+	return requestNumber < run.parameters.Requests
+}
+
+func (engine *HttpEngine) execute(run *testRun, workerId int, dispatch Dispatch) {
+	run.wg.Add(1)
+
+	go func() {
+		defer func() {
+			run.release(workerId)
+			run.wg.Done()
 		}()
+
+		if run.isStopped() {
+			return
+		}
+
+		result, err := engine.request(run.requestCtx, run.parameters, workerId)
+		result.Dispatch = dispatch
+		result.Dispatch.Warmup = run.warmup
+
+		engine.processHttpCodes(run.parameters, &result)
+		engine.processAssertions(&result)
+
+		run.pipeline.send(
+			MeasurementResult{
+				RequestResult: result,
+				Error:         err,
+			},
+		)
+	}()
+}
+
+func (engine *HttpEngine) missDispatch(run *testRun, scheduled time.Time) {
+	resource, err := engine.resourceFeeder.GetNextValue()
+
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	run.pipeline.send(
+		MeasurementResult{
+			RequestResult: RequestResult{
+				Resource: resource,
+				Dispatch: Dispatch{
+					Scheduled: scheduled,
+					Lag:       time.Since(scheduled),
+					Missed:    true,
+					Warmup:    run.warmup,
+				},
+			},
+		},
+	)
+}
+
+func newDispatch(scheduled time.Time, interval time.Duration) Dispatch {
+	lag := time.Since(scheduled)
+
+	return Dispatch{
+		Scheduled: scheduled,
+		Lag:       lag,
+		Late:      lag > min(max(interval, minDispatchLag), maxDispatchLag),
 	}
+}
 
-	wg.Wait()
-	close(concurrencyCh)
+func requestObservers(sinks []ResultSink) []RequestObserver {
+	observers := make([]RequestObserver, 0)
 
-	onProgress(engine.Progress)
-	return results, time.Since(testStartTime)
+	for _, sink := range sinks {
+		if observer, ok := sink.(RequestObserver); ok {
+			observers = append(observers, observer)
+		}
+	}
+
+	return observers
+}
+
+func (engine *HttpEngine) notifyRequestStarted(resource Resource) {
+	for _, observer := range engine.observers {
+		observer.RequestStarted(resource)
+	}
+}
+
+func (engine *HttpEngine) notifyRequestFinished(resource Resource) {
+	for _, observer := range engine.observers {
+		observer.RequestFinished(resource)
+	}
 }
 
 func (engine *HttpEngine) timeLimitReached(testStartTime time.Time, timeLimit time.Duration) bool {
@@ -113,34 +326,57 @@ func (engine *HttpEngine) updateProgress(updateFailedRequests bool) {
 }
 
 func (engine *HttpEngine) GetProgress() RequestsProgress {
+	engine.progressMutex.Lock()
+	defer engine.progressMutex.Unlock()
+
 	return engine.Progress
 }
 
-func (engine *HttpEngine) request(parameters Parameters) (RequestResult, error) {
+func (engine *HttpEngine) request(ctx context.Context, parameters Parameters, workerId int) (RequestResult, error) {
 	request, err := engine.newRequest(parameters)
-	client := engine.newClient(parameters, request)
+	client := engine.clients.get(workerId)
 
 	if err != nil {
 		return RequestResult{}, err
 	}
 
-	var result RequestResult
+	result := RequestResult{WorkerId: workerId}
 
-	trace := engine.newClientTrace(&result)
-	request = request.WithContext(httptrace.WithClientTrace(context.Background(), trace))
+	resource := Resource{Url: request.URL}
+	engine.notifyRequestStarted(resource)
+	defer engine.notifyRequestFinished(resource)
+
+	trace := &requestTrace{}
+	request = request.WithContext(httptrace.WithClientTrace(ctx, engine.newClientTrace(trace)))
 
 	engine.setHeaders(parameters, request)
+	result.Trace = injectTraceContext(request)
+
+	var requestBody *bodyCapture
+
+	if sequence := engine.dumpSequence(parameters); sequence > 0 {
+		result.Exchange = &Exchange{Sequence: sequence}
+		requestBody = result.Exchange.captureRequest(request, parameters.DumpBodySize)
+		trace.dump = true
+	}
 
 	var response *http.Response
 	response, err = client.Do(request)
 
-	result.Resource.Url = request.URL
+	trace.finish(&result)
 
-	result.Timing.TotalTime = time.Now()
+	if requestBody != nil {
+		result.Exchange.RequestBody = requestBody.excerpt()
+	}
 
-	engine.calculateDurations(&result)
+	result.Resource.Url = request.URL
+
+	result.Timing.HeadersReceived = time.Now()
 
 	if err != nil {
+		result.Timing.TotalTime = result.Timing.HeadersReceived
+		engine.calculateDurations(&result)
+
 		return result, &ResponseError{
 			Message: "Failed to read response",
 			Err:     err,
@@ -149,6 +385,34 @@ func (engine *HttpEngine) request(parameters Parameters) (RequestResult, error)
 
 	defer response.Body.Close()
 
+	// the body is kept only if the assertions need it
+	body := &limitedBuffer{}
+
+	if needsBody(parameters.Assertions) {
+		body.limit = maxAssertionBodySize
+	}
+
+	if result.Exchange != nil {
+		body.limit = max(body.limit, parameters.DumpBodySize)
+	}
+
+	result.BytesReceived.Headers = engine.headersSize(response)
+	result.BytesReceived.Body, result.BytesReceived.Decoded, err = engine.readBody(response, body)
+
+	result.Timing.TotalTime = time.Now()
+	engine.calculateDurations(&result)
+
+	if result.Exchange != nil {
+		result.Exchange.captureResponse(response, body.Bytes(), result.BytesReceived.Decoded, parameters.DumpBodySize)
+	}
+
+	if err != nil {
+		return result, &ResponseError{
+			Message: "Failed to read response body",
+			Err:     err,
+		}
+	}
+
 	result.Status = response.Status
 	result.StatusCode = response.StatusCode
 	result.ContentLength = response.ContentLength
@@ -156,6 +420,11 @@ func (engine *HttpEngine) request(parameters Parameters) (RequestResult, error)
 	engine.fillTLSInfo(response, &result)
 	engine.fillHeaders(response, &result)
 
+	if len(parameters.Assertions) > 0 {
+		result.AssertionsChecked = true
+		result.FailedAssertions = checkAssertions(parameters.Assertions, response, body.Bytes(), result)
+	}
+
 	return result, nil
 }
 
@@ -170,6 +439,12 @@ func (engine *HttpEngine) setHeaders(parameters Parameters, request *http.Reques
 		request.Header.Set("content-type", parameters.ContentType)
 	}
 
+	// the transport does not decompress responses (to count the bytes received over the wire),
+	// so ask for gzip like the transport would do and decode it while reading the body
+	if request.Method != http.MethodHead {
+		request.Header.Set("accept-encoding", "gzip")
+	}
+
 	if parameters.FormData != "" {
 		request.Header.Set("content-type", "application/x-www-form-urlencoded")
 	}
@@ -183,71 +458,6 @@ func (engine *HttpEngine) setHeaders(parameters Parameters, request *http.Reques
 	}
 }
 
-func (engine *HttpEngine) newClient(parameters Parameters, request *http.Request) *http.Client {
-	proxyURL, _ := url.Parse(parameters.Proxy)
-
-	var network string
-
-	if parameters.IPv4Only {
-		network = "tcp4"
-	} else if parameters.IPv6Only {
-		network = "tcp6"
-	} else {
-		network = "tcp4"
-	}
-
-	transport := &http.Transport{
-		DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
-			return (&net.Dialer{
-				Timeout:   parameters.Timeout,
-				KeepAlive: parameters.IdleConnTimeout,
-			}).DialContext(ctx, network, addr)
-		},
-		MaxIdleConns:          parameters.MaxIdleConnections,
-		MaxIdleConnsPerHost:   0,
-		MaxConnsPerHost:       0,
-		IdleConnTimeout:       parameters.IdleConnTimeout,
-		ResponseHeaderTimeout: parameters.Timeout,
-		DisableKeepAlives:     !parameters.KeepAlive,
-		TLSHandshakeTimeout:   parameters.TLSHandshakeTimeout,
-		ExpectContinueTimeout: 1 * time.Second,
-		ForceAttemptHTTP2:     true,
-	}
-
-	if parameters.Proxy != "" {
-		transport.Proxy = http.ProxyURL(proxyURL)
-	}
-
-	if request.URL.Scheme == "https" {
-		host, _, err := net.SplitHostPort(request.Host)
-
-		if err != nil {
-			host = request.Host
-		}
-
-		certificates, err := engine.readClientPemCertificate(parameters.ClientCertificateFile)
-
-		if err != nil {
-			log.Fatalf("Error: %v", err)
-		}
-
-		transport.TLSClientConfig = &tls.Config{
-			ServerName:         host,
-			InsecureSkipVerify: parameters.AllowInsecureSSL,
-			Certificates:       certificates,
-			MinVersion:         tls.VersionTLS12,
-		}
-	}
-
-	return &http.Client{
-		Transport: transport,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			// do not follow redirects
-			return http.ErrUseLastResponse
-		},
-	}
-}
-
 func (engine *HttpEngine) readClientPemCertificate(filename string) ([]tls.Certificate, error) {
 	if filename == "" {
 		return nil, nil
@@ -352,31 +562,142 @@ func (engine *HttpEngine) getPostDataFileReader(filename string) (*os.File, erro
 	return file, nil
 }
 
-func (engine *HttpEngine) newClientTrace(result *RequestResult) *httptrace.ClientTrace {
+func (engine *HttpEngine) newClientTrace(trace *requestTrace) *httptrace.ClientTrace {
 	return &httptrace.ClientTrace{
 		GetConn: func(hostPort string) {
-			result.Timing.Start = time.Now()
+			trace.record(func(trace *requestTrace) { trace.timing.Start = time.Now() })
 		},
-		GotConn:              func(_ httptrace.GotConnInfo) { result.Timing.ServerConnect = time.Now() },
-		GotFirstResponseByte: func() { result.Timing.TTFB = time.Now() },
-		DNSStart:             func(_ httptrace.DNSStartInfo) { result.Timing.DNSStart = time.Now() },
-		DNSDone:              func(_ httptrace.DNSDoneInfo) { result.Timing.DNSEnd = time.Now() },
-		ConnectStart: func(_, _ string) {
-			if result.Timing.DNSEnd.IsZero() {
-				result.Timing.DNSEnd = time.Now()
-			}
+		GotConn: func(info httptrace.GotConnInfo) {
+			trace.record(
+				func(trace *requestTrace) {
+					trace.timing.ServerConnect = time.Now()
+					trace.connection.Reused = info.Reused
+					trace.connection.WasIdle = info.WasIdle
+					trace.connection.IdleTime = info.IdleTime
+
+					if info.Conn != nil {
+						trace.connection.RemoteAddr = info.Conn.RemoteAddr().String()
+					}
+
+					if info.Reused {
+						trace.event("Reusing the connection to %s (idle %s)", trace.connection.RemoteAddr, info.IdleTime)
+					} else {
+						trace.event("Using a new connection to %s", trace.connection.RemoteAddr)
+					}
+				},
+			)
+		},
+		GotFirstResponseByte: func() {
+			trace.record(
+				func(trace *requestTrace) {
+					trace.timing.TTFB = time.Now()
+					trace.event("First response byte received")
+				},
+			)
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			trace.recordDial(
+				func(trace *requestTrace) {
+					trace.timing.DNSStart = time.Now()
+					trace.event("DNS lookup of %s", info.Host)
+				},
+			)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			trace.recordDial(
+				func(trace *requestTrace) {
+					trace.timing.DNSEnd = time.Now()
+
+					if info.Err != nil {
+						trace.event("DNS lookup failed: %v", info.Err)
+					} else {
+						trace.event("DNS lookup result: %s", joinAddresses(info.Addrs))
+					}
+				},
+			)
+		},
+		ConnectStart: func(network, addr string) {
+			trace.recordDial(
+				func(trace *requestTrace) {
+					if trace.timing.DNSEnd.IsZero() {
+						trace.timing.DNSEnd = time.Now()
+					}
+
+					trace.event("Connecting to %s (%s)", addr, network)
+				},
+			)
 		},
 		ConnectDone: func(net, addr string, err error) {
-			result.Error = err
-			result.Timing.TCPConnect = time.Now()
+			trace.recordDial(
+				func(trace *requestTrace) {
+					trace.err = err
+					trace.timing.TCPConnect = time.Now()
+
+					if err != nil {
+						trace.event("Connection to %s failed: %v", addr, err)
+					} else {
+						trace.event("Connected to %s", addr)
+					}
+				},
+			)
+		},
+		TLSHandshakeStart: func() {
+			trace.recordDial(
+				func(trace *requestTrace) {
+					trace.timing.TLSHandshakeStart = time.Now()
+					trace.event("TLS handshake started")
+				},
+			)
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			trace.recordDial(
+				func(trace *requestTrace) {
+					trace.timing.TLSHandshakeEnd = time.Now()
+
+					if err != nil {
+						trace.event("TLS handshake failed: %v", err)
+						return
+					}
+
+					alpn := state.NegotiatedProtocol
+
+					if alpn == "" {
+						alpn = "none"
+					}
+
+					trace.event(
+						"TLS handshake done: %s, cipher %s, ALPN %s",
+						tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite), alpn,
+					)
+				},
+			)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			trace.record(
+				func(trace *requestTrace) {
+					trace.timing.RequestSent = time.Now()
+
+					if info.Err != nil {
+						trace.event("Failed to send the request: %v", info.Err)
+					} else {
+						trace.event("Request sent")
+					}
+				},
+			)
 		},
-		TLSHandshakeStart: func() { result.Timing.TLSHandshakeStart = time.Now() },
-		TLSHandshakeDone:  func(_ tls.ConnectionState, _ error) { result.Timing.TLSHandshakeEnd = time.Now() },
-		WroteRequest:      func(_ httptrace.WroteRequestInfo) { result.Timing.RequestSent = time.Now() },
 	}
 }
 
 func (engine *HttpEngine) calculateDurations(result *RequestResult) {
+	if result.Timing.DNSEnd.IsZero() {
+		// reused connection, no DNS lookup and no dialing happened
+		result.Timing.DNSEnd = result.Timing.Start
+	}
+
+	if result.Timing.TCPConnect.IsZero() {
+		result.Timing.TCPConnect = result.Timing.DNSEnd
+	}
+
 	if result.Timing.DNSStart.IsZero() {
 		result.Timing.DNSStart = result.Timing.DNSEnd
 	}
@@ -387,22 +708,14 @@ func (engine *HttpEngine) calculateDurations(result *RequestResult) {
 	}
 
 	result.Durations.DNSLookup.Duration = result.Timing.DNSEnd.Sub(result.Timing.DNSStart)
-	result.Durations.DNSLookup.Total = result.Timing.DNSEnd.Sub(result.Timing.Start)
-
 	result.Durations.TCPConnection.Duration = result.Timing.TCPConnect.Sub(result.Timing.DNSEnd)
-	result.Durations.TCPConnection.Total = result.Timing.TCPConnect.Sub(result.Timing.Start)
-
 	result.Durations.TLSHandshake.Duration = result.Timing.TLSHandshakeEnd.Sub(result.Timing.TLSHandshakeStart)
-	result.Durations.TLSHandshake.Total = result.Timing.TLSHandshakeEnd.Sub(result.Timing.Start)
-
 	result.Durations.ConnectionEstablishment.Duration = result.Timing.ServerConnect.Sub(result.Timing.TLSHandshakeEnd)
-	result.Durations.ConnectionEstablishment.Total = result.Timing.ServerConnect.Sub(result.Timing.Start)
-
 	result.Durations.TTFB.Duration = result.Timing.TTFB.Sub(result.Timing.ServerConnect)
-	result.Durations.TTFB.Total = result.Timing.TTFB.Sub(result.Timing.Start)
-
+	result.Durations.ContentTransfer.Duration = result.Timing.TotalTime.Sub(result.Timing.HeadersReceived)
 	result.Durations.Total.Duration = result.Timing.TotalTime.Sub(result.Timing.RequestSent)
-	result.Durations.Total.Total = result.Timing.TotalTime.Sub(result.Timing.Start)
+
+	result.Durations.setTotals(result.Timing)
 }
 
 func (engine *HttpEngine) fillTLSInfo(response *http.Response, result *RequestResult) {
@@ -427,29 +740,19 @@ func (engine *HttpEngine) fillHeaders(response *http.Response, result *RequestRe
 }
 
 func (engine *HttpEngine) processHttpCodes(parameters Parameters, result *RequestResult) {
-	if parameters.ExitWithErrorOnCode != nil && len(parameters.ExitWithErrorOnCode) > 0 {
-		for _, code := range parameters.ExitWithErrorOnCode {
-			intCode, err := strconv.Atoi(code)
-
-			if err == nil {
-				if result.StatusCode == intCode {
-					result.Error = &HttpCodeError{
-						Err: fmt.Errorf("HTTP code: %d", result.StatusCode),
-					}
-				}
+	for _, code := range parameters.ExitWithErrorOnCode {
+		if statusCodeMatches(code, result.StatusCode) {
+			result.Error = &HttpCodeError{
+				Err: fmt.Errorf("HTTP code: %d", result.StatusCode),
 			}
+		}
+	}
+}
 
-			if match, err := helpers.RegexpStringMatch("(?i)^[0-9]{1}xx$", code); err == nil && match {
-				intCode, err := strconv.Atoi(code[:1])
-
-				if err == nil {
-					if result.StatusCode >= intCode*100 && result.StatusCode < (intCode+1)*100 {
-						result.Error = &HttpCodeError{
-							Err: fmt.Errorf("HTTP code: %d", result.StatusCode),
-						}
-					}
-				}
-			}
+func (engine *HttpEngine) processAssertions(result *RequestResult) {
+	if len(result.FailedAssertions) > 0 && result.Error == nil {
+		result.Error = &AssertionError{
+			Assertions: result.FailedAssertions,
 		}
 	}
 }
@@ -0,0 +1,66 @@
+package tester
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// requestTrace collects client trace events of a single request.
+// With a shared transport a dial started for one request may complete after
+// the request has been served by another connection, so late events are dropped.
+type requestTrace struct {
+	mutex      sync.Mutex
+	finished   bool
+	timing     Timing
+	connection ConnectionInfo
+	err        error
+
+	// events are collected only if the request is dumped in the verbose mode
+	dump   bool
+	events []ExchangeEvent
+}
+
+func (trace *requestTrace) record(update func(trace *requestTrace)) {
+	trace.mutex.Lock()
+	defer trace.mutex.Unlock()
+
+	if trace.finished {
+		return
+	}
+
+	update(trace)
+}
+
+// recordDial ignores dial events that arrive after a connection has been obtained
+func (trace *requestTrace) recordDial(update func(trace *requestTrace)) {
+	trace.record(
+		func(trace *requestTrace) {
+			if trace.timing.ServerConnect.IsZero() {
+				update(trace)
+			}
+		},
+	)
+}
+
+// event adds a connection event of a dumped request, it is called by the update functions of record
+func (trace *requestTrace) event(format string, args ...any) {
+	if trace.dump {
+		trace.events = append(trace.events, ExchangeEvent{Time: time.Now(), Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+func (trace *requestTrace) finish(result *RequestResult) {
+	trace.mutex.Lock()
+	defer trace.mutex.Unlock()
+
+	trace.finished = true
+
+	result.Timing = trace.timing
+	result.Connection = trace.connection
+	result.Error = trace.err
+
+	if result.Exchange != nil {
+		result.Exchange.Events = trace.events
+	}
+}
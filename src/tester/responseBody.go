@@ -0,0 +1,85 @@
+package tester
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (reader *countingReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	reader.count += int64(n)
+
+	return n, err
+}
+
+// readBody downloads the whole response body and returns the number of bytes
+// received over the wire and the size of the decoded content. The decoded content
+// is written to keep if it is not nil.
+func (engine *HttpEngine) readBody(response *http.Response, keep io.Writer) (int64, int64, error) {
+	body := &countingReader{reader: response.Body}
+
+	content, err := engine.newContentDecoder(response, body)
+
+	if err != nil {
+		return body.count, 0, err
+	}
+
+	if keep == nil {
+		keep = io.Discard
+	}
+
+	decoded, err := io.Copy(keep, content)
+
+	if err != nil {
+		return body.count, decoded, err
+	}
+
+	// the decoder may stop before the end of the raw body, drain the rest so the connection can be reused
+	_, err = io.Copy(io.Discard, body)
+
+	return body.count, decoded, err
+}
+
+func (engine *HttpEngine) newContentDecoder(response *http.Response, body io.Reader) (io.Reader, error) {
+	var decoder io.Reader
+	var err error
+
+	switch strings.ToLower(response.Header.Get("content-encoding")) {
+	case "gzip", "x-gzip":
+		decoder, err = gzip.NewReader(body)
+	case "deflate":
+		decoder, err = zlib.NewReader(body)
+	default:
+		return body, nil
+	}
+
+	if errors.Is(err, io.EOF) {
+		// empty body, e.g. a response to a HEAD request
+		return body, nil
+	}
+
+	return decoder, err
+}
+
+func (engine *HttpEngine) headersSize(response *http.Response) int64 {
+	// status line
+	size := len(response.Proto) + len(response.Status) + 3
+
+	for name, values := range response.Header {
+		for _, value := range values {
+			size += len(name) + len(value) + 4
+		}
+	}
+
+	// empty line after the headers
+	return int64(size + 2)
+}
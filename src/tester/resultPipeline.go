@@ -0,0 +1,71 @@
+package tester
+
+import "time"
+
+type resultPipeline struct {
+	results  chan pipelineItem
+	done     chan bool
+	sinks    []ResultSink
+	onResult func(result MeasurementResult)
+}
+
+// pipelineItem is a measurement result, or the start of the measured run if runStart is set
+type pipelineItem struct {
+	result   MeasurementResult
+	runStart time.Time
+}
+
+func newResultPipeline(
+	sinks []ResultSink, bufferSize int, onResult func(result MeasurementResult),
+) *resultPipeline {
+	return &resultPipeline{
+		results:  make(chan pipelineItem, bufferSize),
+		done:     make(chan bool),
+		sinks:    sinks,
+		onResult: onResult,
+	}
+}
+
+func (pipeline *resultPipeline) start() {
+	go func() {
+		defer close(pipeline.done)
+
+		for item := range pipeline.results {
+			if !item.runStart.IsZero() {
+				pipeline.notifyRunStarted(item.runStart)
+				continue
+			}
+
+			if pipeline.onResult != nil {
+				pipeline.onResult(item.result)
+			}
+
+			for _, sink := range pipeline.sinks {
+				sink.Consume(item.result)
+			}
+		}
+	}()
+}
+
+func (pipeline *resultPipeline) send(result MeasurementResult) {
+	pipeline.results <- pipelineItem{result: result}
+}
+
+// runStarted passes the start of the measured run to the sinks in order with the results
+func (pipeline *resultPipeline) runStarted(start time.Time) {
+	pipeline.results <- pipelineItem{runStart: start}
+}
+
+func (pipeline *resultPipeline) notifyRunStarted(start time.Time) {
+	for _, sink := range pipeline.sinks {
+		if observer, ok := sink.(RunObserver); ok {
+			observer.RunStarted(start)
+		}
+	}
+}
+
+// close waits until every result sent so far has been delivered to all sinks
+func (pipeline *resultPipeline) close() {
+	close(pipeline.results)
+	<-pipeline.done
+}
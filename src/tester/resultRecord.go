@@ -0,0 +1,159 @@
+package tester
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+// ResultRecord is the flat form of a measurement result, e.g. one line of the raw result log
+type ResultRecord struct {
+	Url               string
+	Status            string
+	StatusCode        int
+	ContentLength     int64
+	Headers           ResponseHeaders
+	Timing            Timing
+	Durations         RecordDurations
+	BytesReceived     BytesReceived
+	TLSVersion        string
+	Connection        ConnectionInfo
+	Dispatch          Dispatch
+	WorkerId          int
+	TraceId           string   `json:",omitempty"`
+	SpanId            string   `json:",omitempty"`
+	ParentSpanId      string   `json:",omitempty"`
+	AssertionsChecked bool     `json:",omitempty"`
+	FailedAssertions  []string `json:",omitempty"`
+	ErrorClass        string   `json:",omitempty"`
+	Error             string   `json:",omitempty"`
+}
+
+type RecordDurations struct {
+	DNSLookup,
+	TCPConnection,
+	TLSHandshake,
+	ConnectionEstablishment,
+	TTFB,
+	ContentTransfer,
+	Total time.Duration
+
+	// CorrectedTotal is only set for the requests sent on schedule in the open model (-rate)
+	CorrectedTotal time.Duration `json:",omitempty"`
+}
+
+// PrimaryError returns the error which failed the request, a request error takes precedence over the HTTP code
+func (result MeasurementResult) PrimaryError() error {
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return result.RequestResult.Error
+}
+
+func (result MeasurementResult) Record() ResultRecord {
+	requestResult := result.RequestResult
+
+	record := ResultRecord{
+		Status:        requestResult.Status,
+		StatusCode:    requestResult.StatusCode,
+		ContentLength: requestResult.ContentLength,
+		Headers:       requestResult.Headers,
+		Timing:        requestResult.Timing,
+		Durations: RecordDurations{
+			DNSLookup:               requestResult.Durations.DNSLookup.Duration,
+			TCPConnection:           requestResult.Durations.TCPConnection.Duration,
+			TLSHandshake:            requestResult.Durations.TLSHandshake.Duration,
+			ConnectionEstablishment: requestResult.Durations.ConnectionEstablishment.Duration,
+			TTFB:                    requestResult.Durations.TTFB.Duration,
+			ContentTransfer:         requestResult.Durations.ContentTransfer.Duration,
+			Total:                   requestResult.Durations.Total.Total,
+		},
+		BytesReceived:     requestResult.BytesReceived,
+		TLSVersion:        requestResult.TLS.TLSVersion,
+		Connection:        requestResult.Connection,
+		Dispatch:          requestResult.Dispatch,
+		WorkerId:          requestResult.WorkerId,
+		TraceId:           requestResult.Trace.TraceId,
+		SpanId:            requestResult.Trace.SpanId,
+		ParentSpanId:      requestResult.Trace.ParentSpanId,
+		AssertionsChecked: requestResult.AssertionsChecked,
+		FailedAssertions:  requestResult.FailedAssertions,
+	}
+
+	if !requestResult.Dispatch.Scheduled.IsZero() && !requestResult.Dispatch.Missed {
+		record.Durations.CorrectedTotal = requestResult.CorrectedTotal()
+	}
+
+	if requestResult.Resource.Url != nil {
+		record.Url = requestResult.Resource.Url.String()
+	}
+
+	if err := result.PrimaryError(); err != nil {
+		record.ErrorClass = ClassifyError(err)
+		record.Error = err.Error()
+	}
+
+	return record
+}
+
+// MeasurementResult restores the measurement result from the record. The durations from the start
+// of the request (Duration.Total) are not recorded, they are restored from the timing of the request.
+func (record ResultRecord) MeasurementResult() (MeasurementResult, error) {
+	requestUrl, err := url.Parse(record.Url)
+
+	if err != nil {
+		return MeasurementResult{}, err
+	}
+
+	result := MeasurementResult{
+		RequestResult: RequestResult{
+			Resource:      Resource{Url: requestUrl},
+			Status:        record.Status,
+			StatusCode:    record.StatusCode,
+			ContentLength: record.ContentLength,
+			Timing:        record.Timing,
+			Durations: Durations{
+				DNSLookup:               Duration{Duration: record.Durations.DNSLookup},
+				TCPConnection:           Duration{Duration: record.Durations.TCPConnection},
+				TLSHandshake:            Duration{Duration: record.Durations.TLSHandshake},
+				ConnectionEstablishment: Duration{Duration: record.Durations.ConnectionEstablishment},
+				TTFB:                    Duration{Duration: record.Durations.TTFB},
+				ContentTransfer:         Duration{Duration: record.Durations.ContentTransfer},
+				Total:                   Duration{Total: record.Durations.Total},
+			},
+			TLS: TLS{
+				UseTLS:     record.TLSVersion != "",
+				TLSVersion: record.TLSVersion,
+			},
+			Headers:       record.Headers,
+			BytesReceived: record.BytesReceived,
+			Connection:    record.Connection,
+			Dispatch:      record.Dispatch,
+			WorkerId:      record.WorkerId,
+			Trace: TraceContext{
+				TraceId: record.TraceId, SpanId: record.SpanId, ParentSpanId: record.ParentSpanId,
+			},
+			AssertionsChecked: record.AssertionsChecked,
+			FailedAssertions:  record.FailedAssertions,
+		},
+	}
+
+	if !record.Timing.Start.IsZero() {
+		result.RequestResult.Durations.setTotals(record.Timing)
+		result.RequestResult.Durations.Total.Total = record.Durations.Total
+	}
+
+	if record.ErrorClass != "" {
+		result.Error = &RecordedError{
+			Class:   record.ErrorClass,
+			Message: record.Error,
+		}
+	}
+
+	return result, nil
+}
+
+func (result MeasurementResult) ToJson() ([]byte, error) {
+	return json.Marshal(result.Record())
+}
@@ -0,0 +1,179 @@
+package tester
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResultRecordRoundTrip(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	scheduled := start.Add(-30 * time.Millisecond)
+	requestUrl, _ := url.Parse("https://localhost:8443/api?id=1")
+
+	timing := Timing{
+		Start:             start,
+		DNSStart:          start,
+		DNSEnd:            start.Add(2 * time.Millisecond),
+		TCPConnect:        start.Add(5 * time.Millisecond),
+		TLSHandshakeStart: start.Add(5 * time.Millisecond),
+		TLSHandshakeEnd:   start.Add(15 * time.Millisecond),
+		ServerConnect:     start.Add(15 * time.Millisecond),
+		RequestSent:       start.Add(16 * time.Millisecond),
+		TTFB:              start.Add(40 * time.Millisecond),
+		HeadersReceived:   start.Add(40 * time.Millisecond),
+		TotalTime:         start.Add(50 * time.Millisecond),
+	}
+
+	durations := Durations{
+		DNSLookup:               Duration{Duration: 2 * time.Millisecond},
+		TCPConnection:           Duration{Duration: 3 * time.Millisecond},
+		TLSHandshake:            Duration{Duration: 10 * time.Millisecond},
+		ConnectionEstablishment: Duration{Duration: 15 * time.Millisecond},
+		TTFB:                    Duration{Duration: 24 * time.Millisecond},
+		ContentTransfer:         Duration{Duration: 10 * time.Millisecond},
+	}
+
+	durations.setTotals(timing)
+
+	succeeded := MeasurementResult{
+		RequestResult: RequestResult{
+			Resource:      Resource{Url: requestUrl},
+			Status:        "200 OK",
+			StatusCode:    200,
+			ContentLength: 512,
+			Timing:        timing,
+			Durations:     durations,
+			TLS:           TLS{UseTLS: true, TLSVersion: "TLS 1.3"},
+			Headers:       ResponseHeaders{Server: "nginx"},
+			BytesReceived: BytesReceived{Headers: 120, Body: 300, Decoded: 512},
+			Connection:    ConnectionInfo{RemoteAddr: "127.0.0.1:8443"},
+			Dispatch:      Dispatch{Stage: 1, Scheduled: scheduled, Lag: 30 * time.Millisecond, Late: true},
+			WorkerId:      3,
+			Trace: TraceContext{
+				TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7", ParentSpanId: "b7ad6b7169203331",
+			},
+			AssertionsChecked: true,
+		},
+	}
+
+	failed := MeasurementResult{
+		RequestResult: RequestResult{
+			Resource:          Resource{Url: requestUrl},
+			Status:            "500 Internal Server Error",
+			StatusCode:        500,
+			Timing:            timing,
+			Durations:         durations,
+			Connection:        ConnectionInfo{Reused: true, WasIdle: true, IdleTime: time.Second},
+			AssertionsChecked: true,
+			FailedAssertions:  []string{"status:2xx"},
+			Error:             &HttpCodeError{Err: errors.New("500 Internal Server Error")},
+		},
+		Error: &AssertionError{Assertions: []string{"status:2xx"}},
+	}
+
+	missed := MeasurementResult{
+		RequestResult: RequestResult{
+			Resource: Resource{Url: requestUrl},
+			Dispatch: Dispatch{Scheduled: scheduled, Lag: 2 * time.Second, Missed: true},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		result     MeasurementResult
+		errorClass string
+	}{
+		{"succeeded", succeeded, ""},
+		{"failed", failed, ErrorClassAssertion},
+		{"missed dispatch", missed, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				line, err := test.result.ToJson()
+
+				if err != nil {
+					t.Fatalf("ToJson() returned an error: %v", err)
+				}
+
+				var record ResultRecord
+
+				if err := json.Unmarshal(line, &record); err != nil {
+					t.Fatalf("unable to decode %s: %v", line, err)
+				}
+
+				if !reflect.DeepEqual(record, test.result.Record()) {
+					t.Errorf("decoded record = %+v, want %+v", record, test.result.Record())
+				}
+
+				restored, err := record.MeasurementResult()
+
+				if err != nil {
+					t.Fatalf("MeasurementResult() returned an error: %v", err)
+				}
+
+				requestResult := restored.RequestResult
+
+				if got := requestResult.Resource.Url.String(); got != requestUrl.String() {
+					t.Errorf("Url = %s, want %s", got, requestUrl)
+				}
+
+				if requestResult.Durations != test.result.RequestResult.Durations {
+					t.Errorf("Durations = %+v, want %+v", requestResult.Durations, test.result.RequestResult.Durations)
+				}
+
+				if got, want := requestResult.CorrectedTotal(), test.result.RequestResult.CorrectedTotal(); got != want {
+					t.Errorf("CorrectedTotal() = %s, want %s", got, want)
+				}
+
+				if requestResult.TLS != test.result.RequestResult.TLS {
+					t.Errorf("TLS = %+v, want %+v", requestResult.TLS, test.result.RequestResult.TLS)
+				}
+
+				if requestResult.Trace != test.result.RequestResult.Trace {
+					t.Errorf("Trace = %+v, want %+v", requestResult.Trace, test.result.RequestResult.Trace)
+				}
+
+				if !restored.StartedAt().Equal(test.result.StartedAt()) {
+					t.Errorf("StartedAt() = %s, want %s", restored.StartedAt(), test.result.StartedAt())
+				}
+
+				if got := ClassifyError(restored.PrimaryError()); got != test.errorClass {
+					t.Errorf("ClassifyError(PrimaryError()) = %q, want %q", got, test.errorClass)
+				}
+
+				if !reflect.DeepEqual(restored.Record(), record) {
+					t.Errorf("Record() of the restored result = %+v, want %+v", restored.Record(), record)
+				}
+			},
+		)
+	}
+}
+
+func TestResultRecordInvalidUrl(t *testing.T) {
+	if _, err := (ResultRecord{Url: "http://[::1"}).MeasurementResult(); err == nil {
+		t.Errorf("MeasurementResult() of an invalid URL did not return an error")
+	}
+}
+
+func TestRequestResultToJson(t *testing.T) {
+	requestUrl, _ := url.Parse("http://localhost/")
+	result := RequestResult{Resource: Resource{Url: requestUrl}, Status: "200 OK", StatusCode: 200}
+
+	got, err := result.ToJson()
+
+	if err != nil {
+		t.Fatalf("ToJson() returned an error: %v", err)
+	}
+
+	want, _ := MeasurementResult{RequestResult: result}.ToJson()
+
+	if string(got) != string(want) {
+		t.Errorf("ToJson() = %s, want the raw log record %s", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+package tester
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const stageAdjustInterval = 100 * time.Millisecond
+
+type Stage struct {
+	Duration time.Duration
+	Target   int
+}
+
+// stageController changes the number of workers available in the concurrencyCh semaphore
+// over time. Worker ids which are not allowed to run are parked by the controller.
+type stageController struct {
+	stages        []Stage
+	concurrencyCh chan int
+	stop          chan bool
+	stopped       chan bool
+
+	mutex  sync.Mutex
+	parked []int
+
+	// active counts the ids in the semaphore or held by the workers, revoked is the number of them
+	// to be parked as soon as the workers return them (the dispatch loop takes every idle id)
+	active,
+	revoked int
+}
+
+func newStageController(stages []Stage, concurrencyCh chan int) *stageController {
+	controller := &stageController{
+		stages:        stages,
+		concurrencyCh: concurrencyCh,
+		parked:        make([]int, 0, cap(concurrencyCh)),
+		stop:          make(chan bool),
+		stopped:       make(chan bool),
+	}
+
+	for workerId := cap(concurrencyCh) - 1; workerId >= 0; workerId-- {
+		controller.parked = append(controller.parked, workerId)
+	}
+
+	return controller
+}
+
+func MaxStageTarget(stages []Stage) int {
+	target := 0
+
+	for _, stage := range stages {
+		target = max(target, stage.Target)
+	}
+
+	return target
+}
+
+func StagesDuration(stages []Stage) time.Duration {
+	var duration time.Duration
+
+	for _, stage := range stages {
+		duration += stage.Duration
+	}
+
+	return duration
+}
+
+func (controller *stageController) start(startTime time.Time) {
+	_, target := controller.targetAt(0)
+	controller.adjust(target)
+
+	go func() {
+		defer close(controller.stopped)
+
+		ticker := time.NewTicker(stageAdjustInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-controller.stop:
+				return
+			case <-ticker.C:
+				_, target := controller.targetAt(time.Since(startTime))
+				controller.adjust(target)
+			}
+		}
+	}()
+}
+
+func (controller *stageController) close() {
+	close(controller.stop)
+	<-controller.stopped
+}
+
+// targetAt returns the 1-based number of the stage and the number of workers for the given moment
+func (controller *stageController) targetAt(elapsed time.Duration) (int, int) {
+	from := 0
+
+	for index, stage := range controller.stages {
+		if elapsed < stage.Duration {
+			progress := float64(elapsed) / float64(stage.Duration)
+			return index + 1, from + int(math.Round(float64(stage.Target-from)*progress))
+		}
+
+		elapsed -= stage.Duration
+		from = stage.Target
+	}
+
+	return len(controller.stages), from
+}
+
+func (controller *stageController) adjust(target int) {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	for controller.active-controller.revoked > target {
+		select {
+		case workerId := <-controller.concurrencyCh:
+			controller.parked = append(controller.parked, workerId)
+			controller.active--
+		default:
+			controller.revoked++
+		}
+	}
+
+	for controller.active-controller.revoked < target {
+		if controller.revoked > 0 {
+			controller.revoked--
+			continue
+		}
+
+		if len(controller.parked) == 0 {
+			return
+		}
+
+		workerId := controller.parked[len(controller.parked)-1]
+		controller.parked = controller.parked[:len(controller.parked)-1]
+		controller.concurrencyCh <- workerId
+		controller.active++
+	}
+}
+
+// park takes the id returned by a worker if the number of workers has been reduced in the meantime
+func (controller *stageController) park(workerId int) bool {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	if controller.revoked == 0 {
+		return false
+	}
+
+	controller.parked = append(controller.parked, workerId)
+	controller.revoked--
+	controller.active--
+
+	return true
+}
+
+// workers returns the number of workers allowed to run
+func (controller *stageController) workers() int {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	return controller.active - controller.revoked
+}
@@ -0,0 +1,146 @@
+package tester
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTargetAt(t *testing.T) {
+	controller := newStageController(
+		[]Stage{{Duration: time.Second, Target: 10}, {Duration: time.Second, Target: 10}, {Duration: 2 * time.Second}},
+		make(chan int, 10),
+	)
+
+	tests := []struct {
+		elapsed time.Duration
+		stage   int
+		target  int
+	}{
+		{0, 1, 0},
+		{500 * time.Millisecond, 1, 5},
+		{time.Second, 2, 10},
+		{1500 * time.Millisecond, 2, 10},
+		{2500 * time.Millisecond, 3, 7},
+		{3 * time.Second, 3, 5},
+		{5 * time.Second, 3, 0},
+	}
+
+	for _, test := range tests {
+		stage, target := controller.targetAt(test.elapsed)
+
+		if stage != test.stage || target != test.target {
+			t.Errorf("targetAt(%s) = %d, %d, want %d, %d", test.elapsed, stage, target, test.stage, test.target)
+		}
+	}
+}
+
+func TestStageControllerRampDown(t *testing.T) {
+	concurrencyCh := make(chan int, 4)
+	controller := newStageController([]Stage{{Duration: time.Second, Target: 4}}, concurrencyCh)
+	run := &testRun{concurrencyCh: concurrencyCh, stages: controller}
+
+	controller.adjust(4)
+
+	// all the workers are busy, like in the closed model where the dispatch loop takes every idle id
+	busy := make([]int, 0, 4)
+
+	for len(concurrencyCh) > 0 {
+		busy = append(busy, <-concurrencyCh)
+	}
+
+	controller.adjust(1)
+
+	if workers := controller.workers(); workers != 1 {
+		t.Errorf("workers() after the ramp-down = %d, want 1", workers)
+	}
+
+	for _, workerId := range busy {
+		run.release(workerId)
+	}
+
+	if len(concurrencyCh) != 1 {
+		t.Errorf("%d workers returned to the semaphore, want 1", len(concurrencyCh))
+	}
+
+	controller.adjust(3)
+
+	if len(concurrencyCh) != 3 || controller.workers() != 3 {
+		t.Errorf("after the ramp-up %d ids are idle and workers() = %d, want 3", len(concurrencyCh), controller.workers())
+	}
+
+	// a ramp-up cancels the revocations pending for the busy workers before unparking the ids
+	<-concurrencyCh
+	controller.adjust(0)
+	controller.adjust(2)
+
+	run.release(busy[0])
+
+	if len(concurrencyCh) != 2 || controller.workers() != 2 {
+		t.Errorf(
+			"after the ramp-down and up %d ids are idle and workers() = %d, want 2",
+			len(concurrencyCh), controller.workers(),
+		)
+	}
+}
+
+func TestMeasureRampDown(t *testing.T) {
+	start := time.Now()
+
+	var mutex sync.Mutex
+	inFlight := 0
+	maxInFlight := make(map[time.Duration]int)
+
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(writer http.ResponseWriter, request *http.Request) {
+				mutex.Lock()
+				inFlight++
+				window := time.Since(start).Truncate(300 * time.Millisecond)
+				maxInFlight[window] = max(maxInFlight[window], inFlight)
+				mutex.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				mutex.Lock()
+				inFlight--
+				mutex.Unlock()
+			},
+		),
+	)
+
+	defer server.Close()
+
+	serverUrl, _ := url.Parse(server.URL)
+	stages := []Stage{{Duration: 300 * time.Millisecond, Target: 8}, {Duration: 900 * time.Millisecond}}
+
+	parameters := Parameters{
+		Resources:   []Resource{{Url: serverUrl}},
+		Concurrency: MaxStageTarget(stages),
+		Stages:      stages,
+		TimeLimit:   StagesDuration(stages),
+		Method:      http.MethodGet,
+		KeepAlive:   true,
+	}
+
+	if _, err := Test(context.Background(), parameters, func(progress RequestsProgress) {}); err != nil {
+		t.Fatalf("Test() returned an error: %v", err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// the second stage ramps down from 8 to 0 workers, in the last window at most 3 are allowed to run
+	// (plus one as the number of workers is adjusted every stageAdjustInterval)
+	if inRampDown := maxInFlight[300*time.Millisecond]; inRampDown < 6 {
+		t.Errorf("at most %d requests in flight at the start of the ramp-down, want 6 or more", inRampDown)
+	}
+
+	if atEnd := maxInFlight[900*time.Millisecond]; atEnd > 4 {
+		t.Errorf("%d requests in flight at the end of the ramp-down, want at most 4", atEnd)
+	}
+}
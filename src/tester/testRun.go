@@ -0,0 +1,86 @@
+package tester
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type testRun struct {
+	parameters    Parameters
+	pipeline      *resultPipeline
+	concurrencyCh chan int
+	stages        *stageController
+	wg            sync.WaitGroup
+	startTime     time.Time
+
+	// warmup runs are excluded from the statistics
+	warmup bool
+
+	// stopped is closed when no more requests should be dispatched
+	stopped  chan bool
+	stopOnce sync.Once
+
+	// requestCtx is cancelled to abort the requests in flight
+	requestCtx     context.Context
+	cancelRequests context.CancelFunc
+}
+
+func newTestRun(parameters Parameters, pipeline *resultPipeline, warmup bool) *testRun {
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+
+	return &testRun{
+		parameters:     parameters,
+		pipeline:       pipeline,
+		concurrencyCh:  make(chan int, parameters.Concurrency),
+		stopped:        make(chan bool),
+		requestCtx:     requestCtx,
+		cancelRequests: cancelRequests,
+		warmup:         warmup,
+	}
+}
+
+func (run *testRun) stop() {
+	run.stopOnce.Do(func() { close(run.stopped) })
+}
+
+func (run *testRun) isStopped() bool {
+	select {
+	case <-run.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleepUntil returns false if the run has been stopped in the meantime
+func (run *testRun) sleepUntil(moment time.Time) bool {
+	timer := time.NewTimer(time.Until(moment))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-run.stopped:
+		return false
+	}
+}
+
+// release returns the id of a worker which has completed its request to the semaphore
+func (run *testRun) release(workerId int) {
+	if run.stages != nil && run.stages.park(workerId) {
+		return
+	}
+
+	run.concurrencyCh <- workerId
+}
+
+func (run *testRun) stageAt(moment time.Time) int {
+	if run.stages == nil {
+		return 0
+	}
+
+	stage, _ := run.stages.targetAt(moment.Sub(run.startTime))
+
+	return stage
+}
@@ -1,6 +1,7 @@
 package tester
 
 import (
+	"context"
 	"errors"
 	"time"
 )
@@ -10,19 +11,19 @@ var testers = map[string]TestEngine{
 	"https": &HttpEngine{},
 }
 
-func Test(parameters Parameters, onProgress func(progress RequestsProgress)) (
-	[]MeasurementResult, time.Duration, error,
-) {
+func Test(
+	ctx context.Context, parameters Parameters, onProgress func(progress RequestsProgress), sinks ...ResultSink,
+) (time.Duration, error) {
 	testService, ok := testers[parameters.Resources[0].Url.Scheme]
 
 	resourceFeeder := newResourceFeeder(parameters.Resources)
 
 	if ok {
-		measurementResult, duration := testService.Measure(parameters, resourceFeeder, onProgress)
-		return measurementResult, duration, nil
+		duration := testService.Measure(ctx, parameters, resourceFeeder, sinks, onProgress)
+		return duration, nil
 	}
 
-	return []MeasurementResult{}, 0, errors.New("unsupported protocol")
+	return 0, errors.New("unsupported protocol")
 }
 
 func newResourceFeeder(resources []Resource) *ResourceFeeder {
@@ -41,6 +42,9 @@ func (s *ResourceFeeder) GetNextValue() (Resource, error) {
 		return s.Resources[0], nil
 	}
 
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	value := s.Resources[s.index]
 	s.index++
 
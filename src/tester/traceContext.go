@@ -0,0 +1,63 @@
+package tester
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const traceparentHeader = "traceparent"
+
+var traceparentRegexp = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+// TraceContext identifies the request in the W3C Trace Context (traceparent header), so it can be
+// found in the traces of the backend. The span id is the id of the client span of the request.
+type TraceContext struct {
+	TraceId,
+	SpanId,
+	ParentSpanId string
+}
+
+func newTraceContext() TraceContext {
+	ids := make([]byte, 24)
+
+	if _, err := rand.Read(ids); err != nil {
+		return TraceContext{}
+	}
+
+	return TraceContext{
+		TraceId: hex.EncodeToString(ids[:16]),
+		SpanId:  hex.EncodeToString(ids[16:]),
+	}
+}
+
+// injectTraceContext adds a fresh traceparent header to the request. If a traceparent header is set
+// with -H, the requests continue its trace as children of its span, each one with a span id of its own.
+// An invalid traceparent header is sent as is and the request is not traced.
+func injectTraceContext(request *http.Request) TraceContext {
+	trace := newTraceContext()
+
+	if trace.TraceId == "" {
+		return trace
+	}
+
+	flags := "01"
+
+	if traceparent := strings.TrimSpace(request.Header.Get(traceparentHeader)); traceparent != "" {
+		matches := traceparentRegexp.FindStringSubmatch(traceparent)
+
+		if matches == nil {
+			return TraceContext{}
+		}
+
+		trace.TraceId = matches[1]
+		trace.ParentSpanId = matches[2]
+		flags = matches[3]
+	}
+
+	request.Header.Set(traceparentHeader, "00-"+trace.TraceId+"-"+trace.SpanId+"-"+flags)
+
+	return trace
+}
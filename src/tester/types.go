@@ -1,8 +1,9 @@
 package tester
 
 import (
-	"encoding/json"
+	"context"
 	"net/url"
+	"sync"
 	"time"
 )
 
@@ -14,11 +15,14 @@ type Parameters struct {
 	Resources             []Resource
 	Requests              int
 	Concurrency           int
+	Rate                  float64
+	Stages                []Stage
 	Timeout               time.Duration
 	Method                string
 	UserAgent             string
 	UserAgentTemplate     string
 	KeepAlive             bool
+	ConnectionPool        string
 	Proxy                 string
 	MaxIdleConnections    int
 	IdleConnTimeout       time.Duration
@@ -36,14 +40,41 @@ type Parameters struct {
 	TimeLimit             time.Duration
 	URLListFile           string
 	ExitWithErrorOnCode   []string
+	GracePeriod           time.Duration
+	WarmupRequests        int
+	WarmupDuration        time.Duration
+	Percentiles           []float64
+	TimelineInterval      time.Duration
+	SortBy                string
+	Assertions            []Assertion
+	Thresholds            []string
+	RawLogFile            string
+	PrometheusAddress     string
+	InfluxUrl             string
+	StatsdAddress         string
+	GraphiteAddress       string
+	ExportInterval        time.Duration
+	RunId                 string
+	OtlpUrl               string
+	SlowestRequests       int
+	FastestRequests       int
+	DumpRequests          int
+	DumpSample            int
+	DumpFile              string
+	DumpBodySize          int
+	OutputFile            string
+	OutputAppend          bool
+	OutputHistograms      bool
 }
 
 type TestEngine interface {
 	Measure(
+		ctx context.Context,
 		parameters Parameters,
 		resourceFeeder *ResourceFeeder,
+		sinks []ResultSink,
 		onProgress func(progress RequestsProgress),
-	) ([]MeasurementResult, time.Duration)
+	) time.Duration
 
 	GetProgress() RequestsProgress
 }
@@ -64,6 +95,7 @@ type Timing struct {
 	TLSHandshakeStart,
 	TLSHandshakeEnd,
 	RequestSent,
+	HeadersReceived,
 	TotalTime time.Time
 }
 
@@ -78,14 +110,52 @@ type Durations struct {
 	TLSHandshake,
 	ConnectionEstablishment,
 	TTFB,
+	ContentTransfer,
 	Total Duration
 }
 
+// setTotals sets the durations from the start of the request to the end of every phase
+func (durations *Durations) setTotals(timing Timing) {
+	durations.DNSLookup.Total = timing.DNSEnd.Sub(timing.Start)
+	durations.TCPConnection.Total = timing.TCPConnect.Sub(timing.Start)
+	durations.TLSHandshake.Total = timing.TLSHandshakeEnd.Sub(timing.Start)
+	durations.ConnectionEstablishment.Total = timing.ServerConnect.Sub(timing.Start)
+	durations.TTFB.Total = timing.TTFB.Sub(timing.Start)
+	durations.ContentTransfer.Total = timing.TotalTime.Sub(timing.Start)
+	durations.Total.Total = timing.TotalTime.Sub(timing.Start)
+}
+
 type TLS struct {
 	UseTLS     bool
 	TLSVersion string
 }
 
+type ConnectionInfo struct {
+	Reused,
+	WasIdle bool
+	IdleTime   time.Duration
+	RemoteAddr string
+}
+
+// Dispatch describes when a request was due to be sent in the open model (-rate)
+// and which phase of the test (warm-up, stage of the load profile) it belongs to
+type Dispatch struct {
+	Stage     int
+	Scheduled time.Time
+	Lag       time.Duration
+	Late,
+	Missed,
+	Warmup bool
+}
+
+// BytesReceived holds the size of the response headers, the body as received
+// over the wire and the body after content decoding (gzip, deflate)
+type BytesReceived struct {
+	Headers,
+	Body,
+	Decoded int64
+}
+
 type ResponseHeaders struct {
 	Server,
 	PoweredBy string
@@ -100,11 +170,35 @@ type RequestResult struct {
 	Durations     Durations
 	TLS           TLS
 	Headers       ResponseHeaders
-	Error         error
+	BytesReceived BytesReceived
+	Connection    ConnectionInfo
+	Dispatch      Dispatch
+	WorkerId      int
+	Trace         TraceContext
+
+	// Exchange is the dump of the request and the response in the verbose mode, nil if the request is not dumped
+	Exchange *Exchange
+
+	// AssertionsChecked is set if the response has been checked against the assertions (-A)
+	AssertionsChecked bool
+	FailedAssertions  []string
+
+	Error error
 }
 
+// CorrectedTotal measures the request from its scheduled start rather than from the moment it was
+// actually sent, so requests delayed behind a stalled one are not reported as fast (coordinated omission)
+func (result RequestResult) CorrectedTotal() time.Duration {
+	if result.Dispatch.Scheduled.IsZero() {
+		return result.Durations.Total.Total
+	}
+
+	return result.Timing.TotalTime.Sub(result.Dispatch.Scheduled)
+}
+
+// ToJson encodes the result in the format of the raw result log, see ResultRecord
 func (result RequestResult) ToJson() ([]byte, error) {
-	return json.MarshalIndent(result, "", "  ")
+	return MeasurementResult{RequestResult: result}.ToJson()
 }
 
 type MeasurementResult struct {
@@ -112,7 +206,57 @@ type MeasurementResult struct {
 	Error         error
 }
 
+// StartedAt returns the time the request was scheduled or sent at
+func (result MeasurementResult) StartedAt() time.Time {
+	dispatch := result.RequestResult.Dispatch
+
+	if !dispatch.Scheduled.IsZero() {
+		return dispatch.Scheduled
+	}
+
+	if !result.RequestResult.Timing.Start.IsZero() {
+		return result.RequestResult.Timing.Start
+	}
+
+	return result.CompletedAt()
+}
+
+// CompletedAt returns the time the request was completed at, or given up if the dispatch was missed
+func (result MeasurementResult) CompletedAt() time.Time {
+	dispatch := result.RequestResult.Dispatch
+
+	if !result.RequestResult.Timing.TotalTime.IsZero() {
+		return result.RequestResult.Timing.TotalTime
+	}
+
+	if dispatch.Missed {
+		return dispatch.Scheduled.Add(dispatch.Lag)
+	}
+
+	return time.Now()
+}
+
+// ResultSink receives every measurement result. Sinks are called from a single
+// goroutine, so implementations do not need to be safe for concurrent use.
+type ResultSink interface {
+	Consume(result MeasurementResult)
+}
+
+// RequestObserver is an optional interface of a ResultSink to follow the requests in flight.
+// Unlike Consume, its methods are called from the workers concurrently.
+type RequestObserver interface {
+	RequestStarted(resource Resource)
+	RequestFinished(resource Resource)
+}
+
+// RunObserver is an optional interface of a ResultSink to learn the start of the measured run.
+// RunStarted is called like Consume, after the warm-up results and before the results of the run.
+type RunObserver interface {
+	RunStarted(start time.Time)
+}
+
 type ResourceFeeder struct {
 	Resources []Resource
 	index     int
+	mutex     sync.Mutex
 }
@@ -0,0 +1,281 @@
+package thresholds
+
+import (
+	"fmt"
+	"github.com/vpominchuk/wmetrics/src/statistics"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MetricRequestsPerSecond = "rps"
+	MetricErrorRate         = "error_rate"
+	MetricErrors            = "errors"
+	MetricRequests          = "requests"
+	MetricThroughput        = "throughput"
+)
+
+// TargetAll evaluates a threshold against the statistics of all URLs only
+const TargetAll = "all"
+
+var expressionPattern = regexp.MustCompile(`^(?:(all):)?([a-z0-9_.]+)\s*(<=|>=|<|>)\s*(\S+)$`)
+var percentilePattern = regexp.MustCompile(`^p[0-9]+(\.[0-9]+)?$`)
+
+// Threshold is a condition the statistics must satisfy (-th), for example "p95<250ms" or "all:error_rate<1%"
+type Threshold struct {
+	Expression string
+
+	// Target is empty if every URL is checked, or TargetAll
+	Target string
+
+	// Phase is set for the timing metrics (statistics.PhaseTotal, statistics.PhaseTTFB, ...)
+	Phase string
+	// Metric is avg, min, max, median, a percentile (p95, p99.9, ...) or one of the Metric constants
+	Metric string
+
+	Operator string
+	Value    float64
+}
+
+// Parse parses the threshold expression: [all:][phase.]metric operator value.
+// The phase defaults to total. Times are compared in milliseconds, the error rate in percent.
+func Parse(expression string) (Threshold, error) {
+	matches := expressionPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(expression)))
+
+	if matches == nil {
+		return Threshold{}, fmt.Errorf(
+			"invalid threshold: %s. Expected format is [all:][phase.]metric<value, e.g. p95<250ms", expression,
+		)
+	}
+
+	threshold := Threshold{
+		Expression: expression,
+		Target:     matches[1],
+		Metric:     matches[2],
+		Operator:   matches[3],
+	}
+
+	if phase, metric, found := strings.Cut(threshold.Metric, "."); found && slices.Contains(statistics.Phases, phase) {
+		threshold.Phase = phase
+		threshold.Metric = metric
+	}
+
+	var err error
+
+	switch {
+	case threshold.isTiming():
+		if threshold.Phase == "" {
+			threshold.Phase = statistics.PhaseTotal
+		}
+
+		threshold.Value, err = parseTime(matches[4])
+	case threshold.Phase != "":
+		return Threshold{}, fmt.Errorf("invalid threshold: %s. Unknown timing metric %s", expression, threshold.Metric)
+	case threshold.Metric == MetricErrorRate:
+		threshold.Value, err = strconv.ParseFloat(strings.TrimSuffix(matches[4], "%"), 64)
+	case slices.Contains(
+		[]string{MetricRequestsPerSecond, MetricErrors, MetricRequests, MetricThroughput}, threshold.Metric,
+	):
+		threshold.Value, err = strconv.ParseFloat(matches[4], 64)
+	default:
+		return Threshold{}, fmt.Errorf("invalid threshold: %s. Unknown metric %s", expression, threshold.Metric)
+	}
+
+	if err != nil {
+		return Threshold{}, fmt.Errorf("invalid threshold: %s. Invalid value %s", expression, matches[4])
+	}
+
+	return threshold, nil
+}
+
+// Percentile returns the percentile the threshold needs (95 for p95), or 0
+func (threshold Threshold) Percentile() float64 {
+	if !percentilePattern.MatchString(threshold.Metric) {
+		return 0
+	}
+
+	percentile, _ := strconv.ParseFloat(strings.TrimPrefix(threshold.Metric, "p"), 64)
+
+	return percentile
+}
+
+func (threshold Threshold) isTiming() bool {
+	return slices.Contains([]string{"avg", "min", "max", "median"}, threshold.Metric) ||
+		percentilePattern.MatchString(threshold.Metric)
+}
+
+func (threshold Threshold) unit() string {
+	if threshold.isTiming() {
+		return "ms"
+	}
+
+	if threshold.Metric == MetricErrorRate {
+		return "%"
+	}
+
+	if threshold.Metric == MetricThroughput {
+		return "MB/s"
+	}
+
+	return ""
+}
+
+// Evaluate checks the thresholds and stores the results in the statistics of every URL they apply to
+func Evaluate(thresholds []Threshold, stats statistics.Statistics) {
+	for _, threshold := range thresholds {
+		for _, url := range threshold.urls(stats) {
+			stat := stats[url]
+			actual, measured := threshold.actual(stat)
+
+			stat.Thresholds = append(
+				stat.Thresholds, statistics.ThresholdResult{
+					Threshold: threshold.Expression,
+					Actual:    actual,
+					Unit:      threshold.unit(),
+					NoSamples: !measured,
+					Passed:    measured && threshold.compare(actual),
+				},
+			)
+
+			stats[url] = stat
+		}
+	}
+}
+
+// Failed reports if any threshold has not been met
+func Failed(stats statistics.Statistics) bool {
+	for _, stat := range stats {
+		for _, result := range stat.Thresholds {
+			if !result.Passed {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (threshold Threshold) urls(stats statistics.Statistics) []string {
+	if threshold.Target != TargetAll {
+		return stats.Urls()
+	}
+
+	if _, ok := stats[statistics.AllUrls]; ok {
+		return []string{statistics.AllUrls}
+	}
+
+	// a single URL is the aggregate of all URLs
+	return stats.Urls()
+}
+
+// actual returns the measured value of the metric, or false if there is nothing to measure
+func (threshold Threshold) actual(stat statistics.SingleUrlStatistics) (float64, bool) {
+	switch threshold.Metric {
+	case MetricRequestsPerSecond:
+		if stat.TotalTime <= 0 {
+			return 0, true
+		}
+
+		return float64(stat.TotalRequests) / stat.TotalTime.Seconds(), true
+	case MetricErrorRate:
+		if stat.TotalRequests == 0 {
+			return 0, false
+		}
+
+		return float64(stat.ErrorRequests) / float64(stat.TotalRequests) * 100, true
+	case MetricErrors:
+		return float64(stat.ErrorRequests), true
+	case MetricRequests:
+		return float64(stat.TotalRequests), true
+	case MetricThroughput:
+		return stat.ThroughputMBps, true
+	}
+
+	duration, ok := threshold.phaseDuration(stat)
+
+	return toMilliseconds(duration), ok
+}
+
+func (threshold Threshold) compare(actual float64) bool {
+	switch threshold.Operator {
+	case "<":
+		return actual < threshold.Value
+	case "<=":
+		return actual <= threshold.Value
+	case ">":
+		return actual > threshold.Value
+	case ">=":
+		return actual >= threshold.Value
+	}
+
+	return false
+}
+
+// phaseDuration returns the timing metric of the phase, or false if the phase has no samples.
+// Only the successful requests are timed, so there are none if every request failed.
+func (threshold Threshold) phaseDuration(stat statistics.SingleUrlStatistics) (time.Duration, bool) {
+	// the percentiles are calculated for the phases with samples only
+	percentiles, ok := stat.Percentiles[threshold.Phase]
+
+	if !ok {
+		return 0, false
+	}
+
+	if percentile := threshold.Percentile(); percentile > 0 {
+		duration, ok := percentiles[statistics.PercentileKey(percentile)]
+		return duration, ok
+	}
+
+	summaries := map[string][4]time.Duration{
+		statistics.PhaseDNSLookup: {stat.DNSLookupAvg, stat.DNSLookupMin, stat.DNSLookupMax, stat.DNSLookupMedian},
+		statistics.PhaseTCPConnection: {
+			stat.TCPConnectionAvg, stat.TCPConnectionMin, stat.TCPConnectionMax, stat.TCPConnectionMedian,
+		},
+		statistics.PhaseTLSHandshake: {
+			stat.TLSHandshakeAvg, stat.TLSHandshakeMin, stat.TLSHandshakeMax, stat.TLSHandshakeMedian,
+		},
+		statistics.PhaseConnectionEstablished: {
+			stat.ConnectionEstablishedAvg, stat.ConnectionEstablishedMin, stat.ConnectionEstablishedMax,
+			stat.ConnectionEstablishedMedian,
+		},
+		statistics.PhaseTTFB: {stat.TTFBAvg, stat.TTFBMin, stat.TTFBMax, stat.TTFBMedian},
+		statistics.PhaseContentTransfer: {
+			stat.ContentTransferAvg, stat.ContentTransferMin, stat.ContentTransferMax, stat.ContentTransferMedian,
+		},
+		statistics.PhaseTotal: {stat.RequestTimeAvg, stat.RequestTimeMin, stat.RequestTimeMax, stat.RequestTimeMedian},
+		statistics.PhaseCorrectedTotal: {
+			stat.CorrectedRequestTimeAvg, stat.CorrectedRequestTimeMin, stat.CorrectedRequestTimeMax,
+			stat.CorrectedRequestTimeMedian,
+		},
+	}
+
+	summary, ok := summaries[threshold.Phase]
+
+	if !ok {
+		return 0, false
+	}
+
+	return summary[slices.Index([]string{"avg", "min", "max", "median"}, threshold.Metric)], true
+}
+
+// parseTime parses a time (250ms, 1.5s, ...) to milliseconds, a number without a unit is in milliseconds
+func parseTime(value string) (float64, error) {
+	if milliseconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return milliseconds, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return toMilliseconds(duration), nil
+}
+
+func toMilliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
@@ -0,0 +1,189 @@
+package thresholds
+
+import (
+	"github.com/vpominchuk/wmetrics/src/statistics"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       Threshold
+	}{
+		{"p95<250ms", Threshold{Phase: statistics.PhaseTotal, Metric: "p95", Operator: "<", Value: 250}},
+		{"p99.9 <= 1.5s", Threshold{Phase: statistics.PhaseTotal, Metric: "p99.9", Operator: "<=", Value: 1500}},
+		{"ttfb.p99<100", Threshold{Phase: statistics.PhaseTTFB, Metric: "p99", Operator: "<", Value: 100}},
+		{
+			"total_corrected.avg<1s",
+			Threshold{Phase: statistics.PhaseCorrectedTotal, Metric: "avg", Operator: "<", Value: 1000},
+		},
+		{"median>=2ms", Threshold{Phase: statistics.PhaseTotal, Metric: "median", Operator: ">=", Value: 2}},
+		{"error_rate<1%", Threshold{Metric: MetricErrorRate, Operator: "<", Value: 1}},
+		{"all:rps>500", Threshold{Target: TargetAll, Metric: MetricRequestsPerSecond, Operator: ">", Value: 500}},
+		{"ERRORS<=0", Threshold{Metric: MetricErrors, Operator: "<=", Value: 0}},
+		{"throughput>2.5", Threshold{Metric: MetricThroughput, Operator: ">", Value: 2.5}},
+	}
+
+	for _, test := range tests {
+		threshold, err := Parse(test.expression)
+
+		if err != nil {
+			t.Errorf("Parse(%q) returned an error: %v", test.expression, err)
+			continue
+		}
+
+		test.want.Expression = test.expression
+
+		if threshold != test.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", test.expression, threshold, test.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expression := range []string{
+		"", "p95", "p95=250ms", "p95<fast", "latency<1s", "ttfb.rps>5", "dns.errors<1", "error_rate<high%", "any:rps>1",
+	} {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("Parse(%q) did not return an error", expression)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       float64
+	}{
+		{"p95<1s", 95},
+		{"p95.0<1s", 95},
+		{"ttfb.p99.9<1s", 99.9},
+		{"avg<1s", 0},
+		{"rps>1", 0},
+	}
+
+	for _, test := range tests {
+		threshold, err := Parse(test.expression)
+
+		if err != nil {
+			t.Fatalf("Parse(%q) returned an error: %v", test.expression, err)
+		}
+
+		if got := threshold.Percentile(); got != test.want {
+			t.Errorf("Percentile() of %q = %g, want %g", test.expression, got, test.want)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	measured := statistics.SingleUrlStatistics{
+		TotalRequests:  100,
+		ErrorRequests:  2,
+		TotalTime:      10 * time.Second,
+		RequestTimeAvg: 120 * time.Millisecond,
+		TTFBMax:        80 * time.Millisecond,
+		Percentiles: statistics.Percentiles{
+			statistics.PhaseTotal: {"p95": 240 * time.Millisecond, "p99": 300 * time.Millisecond},
+			statistics.PhaseTTFB:  {"p95": 70 * time.Millisecond},
+		},
+	}
+
+	failed := statistics.SingleUrlStatistics{TotalRequests: 10, ErrorRequests: 10, TotalTime: time.Second}
+
+	tests := []struct {
+		expression string
+		stat       statistics.SingleUrlStatistics
+		actual     float64
+		noSamples  bool
+		passed     bool
+	}{
+		{"p95<250ms", measured, 240, false, true},
+		{"p95.0<250ms", measured, 240, false, true},
+		{"p99<250ms", measured, 300, false, false},
+		{"avg<=120ms", measured, 120, false, true},
+		{"ttfb.max<50ms", measured, 80, false, false},
+		{"ttfb.p95<100ms", measured, 70, false, true},
+		{"rps>=10", measured, 10, false, true},
+		{"error_rate<1%", measured, 2, false, false},
+		{"errors<=2", measured, 2, false, true},
+		{"requests>50", measured, 100, false, true},
+		{"p95<250ms", failed, 0, true, false},
+		{"avg<250ms", failed, 0, true, false},
+		{"total_corrected.avg<1s", measured, 0, true, false},
+		{"error_rate<1%", statistics.SingleUrlStatistics{}, 0, true, false},
+	}
+
+	for _, test := range tests {
+		threshold, err := Parse(test.expression)
+
+		if err != nil {
+			t.Fatalf("Parse(%q) returned an error: %v", test.expression, err)
+		}
+
+		stats := statistics.Statistics{"http://localhost/": test.stat}
+		Evaluate([]Threshold{threshold}, stats)
+
+		results := stats["http://localhost/"].Thresholds
+
+		if len(results) != 1 {
+			t.Fatalf("Evaluate of %q stored %d results, want 1", test.expression, len(results))
+		}
+
+		result := results[0]
+
+		if result.Actual != test.actual || result.NoSamples != test.noSamples || result.Passed != test.passed {
+			t.Errorf(
+				"Evaluate of %q = %+v, want actual %g, no samples %t, passed %t",
+				test.expression, result, test.actual, test.noSamples, test.passed,
+			)
+		}
+
+		if Failed(stats) == test.passed {
+			t.Errorf("Failed() after %q = %t, want %t", test.expression, !test.passed, !test.passed)
+		}
+	}
+}
+
+func TestEvaluateTarget(t *testing.T) {
+	stats := statistics.Statistics{
+		"http://a/":        {TotalRequests: 10},
+		"http://b/":        {TotalRequests: 20},
+		statistics.AllUrls: {TotalRequests: 30},
+	}
+
+	thresholds := make([]Threshold, 0, 2)
+
+	for _, expression := range []string{"requests>15", "all:requests>25"} {
+		threshold, err := Parse(expression)
+
+		if err != nil {
+			t.Fatalf("Parse(%q) returned an error: %v", expression, err)
+		}
+
+		thresholds = append(thresholds, threshold)
+	}
+
+	Evaluate(thresholds, stats)
+
+	want := map[string][]bool{
+		"http://a/":        {false},
+		"http://b/":        {true},
+		statistics.AllUrls: {true},
+	}
+
+	for url, passed := range want {
+		results := stats[url].Thresholds
+
+		if len(results) != len(passed) {
+			t.Errorf("%s has %d threshold results, want %d", url, len(results), len(passed))
+			continue
+		}
+
+		for index, result := range results {
+			if result.Passed != passed[index] {
+				t.Errorf("%s: %s passed = %t, want %t", url, result.Threshold, result.Passed, passed[index])
+			}
+		}
+	}
+}